@@ -16,7 +16,9 @@ import (
 //go:generate mockgen -source=batcher.go -destination mocks/mocks.go -package mocks
 
 var (
-	ErrBatcherClosed = errors.New("batcher closed")
+	ErrBatcherClosed   = errors.New("batcher closed")
+	ErrTaskNotResolved = errors.New("task not resolved by batchFn")
+	ErrBatchTasksDone  = errors.New("all tasks of batch cancelled or resolved")
 )
 
 // BatchableTask represents a batchable task
@@ -30,10 +32,11 @@ type BatchableTask[R any] interface {
 
 // ChanTask uses a done channel to signal resolution of return value and error
 type ChanTask[R any] struct {
-	ctx  context.Context
-	done chan struct{}
-	Ret  R
-	Err  error
+	ctx       context.Context
+	done      chan struct{}
+	intercept atomic.Pointer[func(R, error) bool]
+	Ret       R
+	Err       error
 }
 
 func NewChanTask[R any](ctx context.Context) *ChanTask[R] {
@@ -76,6 +79,9 @@ func (c *ChanTask[R]) Result() (R, error) {
 }
 
 func (c *ChanTask[R]) Resolve(ret R, err error) {
+	if intercept := c.intercept.Load(); intercept != nil && (*intercept)(ret, err) {
+		return
+	}
 	select {
 	case <-c.done:
 		klog.Errorf(c.ctx, "ChanTask.Resolve|called twice, ignored|c.Ret=%v,c.Err=%v|Ret=%v,Err=%v",
@@ -86,6 +92,15 @@ func (c *ChanTask[R]) Resolve(ret R, err error) {
 	}
 }
 
+// Intercept sets fn to be called on Resolve. If fn returns true, the resolution is dropped.
+func (c *ChanTask[R]) Intercept(fn func(ret R, err error) bool) {
+	if fn == nil {
+		c.intercept.Store(nil)
+	} else {
+		c.intercept.Store(&fn)
+	}
+}
+
 // Batcher batches together n BatchableTask's together and executes a logic for a batch of BatchableTask's.
 // It skips BatchableTask's with cancelled Ctx and resolve those tasks with the context's error.
 // Batch logic execution should signal each BatchableTask as done by using its Resolve method.
@@ -105,31 +120,154 @@ type BatchCfg func() (batchRate time.Duration, batchCnt int)
 // BatchFn is called for a batch of tasks collected and triggered by a ChanBatcher per its batchCfg.
 type BatchFn[T any] func([]T)
 
+// BatchCtxFn is a BatchFn also receiving a batch context, which is cancelled (with cause ErrBatchTasksDone) once every
+// task in the batch has been cancelled or resolved, or once the BatchCtxFn returns.
+type BatchCtxFn[T any] func(ctx context.Context, tasks []T)
+
+// withCtx converts a BatchFn to a BatchCtxFn ignoring the batch context.
+func (fn BatchFn[T]) withCtx() BatchCtxFn[T] {
+	if fn == nil {
+		return nil
+	}
+	return func(_ context.Context, tasks []T) {
+		fn(tasks)
+	}
+}
+
+// ChanBatcherOption configures optional behaviours of a ChanBatcher.
+type ChanBatcherOption func(*chanBatcherOpts)
+
+type chanBatcherOpts struct {
+	observer      BatchObserver
+	retry         *RetryCfg
+	unresolved    UnresolvedPolicy
+	highBatchRate time.Duration
+	clock         Clock
+}
+
+// WithBatchObserver sets a BatchObserver to observe tasks and batches of a ChanBatcher.
+func WithBatchObserver(observer BatchObserver) ChanBatcherOption {
+	return func(opts *chanBatcherOpts) {
+		opts.observer = observer
+	}
+}
+
+// UnresolvedPolicy decides what a ChanBatcher does with tasks left unresolved after batchFn returns.
+// It does not apply if retrying is enabled, as unresolved tasks are retried instead.
+type UnresolvedPolicy int
+
+const (
+	UnresolvedError UnresolvedPolicy = iota // logs and resolves unresolved tasks with ErrTaskNotResolved
+	UnresolvedLog                           // only logs unresolved tasks and leaves them unresolved
+	UnresolvedPanic                         // resolves unresolved tasks with ErrTaskNotResolved then panics, for tests
+)
+
+// WithUnresolvedPolicy sets what a ChanBatcher does with tasks left unresolved after batchFn returns, default
+// UnresolvedError.
+func WithUnresolvedPolicy(policy UnresolvedPolicy) ChanBatcherOption {
+	return func(opts *chanBatcherOpts) {
+		opts.unresolved = policy
+	}
+}
+
+// Priority is the priority of a task submitted to a ChanBatcher. Batches are filled with higher priority tasks first.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // priority of tasks submitted via Batch
+	PriorityHigh                   // priority for latency-critical tasks
+)
+
+// WithHighPriorityBatchRate sets a shorter batchRate to use for batches with PriorityHigh tasks. By default, the same
+// batchRate as for PriorityNormal tasks is used.
+func WithHighPriorityBatchRate(batchRate time.Duration) ChanBatcherOption {
+	return func(opts *chanBatcherOpts) {
+		opts.highBatchRate = batchRate
+	}
+}
+
+// WithClock sets the Clock used by a ChanBatcher for lingering, retry backoffs and observed durations, default
+// RealClock. It allows testing time-dependent behaviours deterministically with a FakeClock.
+func WithClock(clock Clock) ChanBatcherOption {
+	return func(opts *chanBatcherOpts) {
+		opts.clock = clock
+	}
+}
+
 // ChanBatcher implements Batcher using golang channel.
 type ChanBatcher[T BatchableTask[R], R any] struct {
-	batchCfg BatchCfg
-	batchFn  BatchFn[T]
-	taskCh   chan T
-	flushCh  chan struct{}
-	closed   atomic.Bool
+	batchCfg      BatchCfg
+	batchFn       BatchCtxFn[T]
+	observer      BatchObserver
+	retry         *RetryCfg
+	unresolved    UnresolvedPolicy
+	highBatchRate time.Duration
+	clock         Clock
+	taskCh        chan batchItem[T]
+	retryCh       chan batchItem[T]
+	flushCh       chan struct{}
+	workerDone    chan struct{}
+	queueLen      atomic.Int64
+	closed        atomic.Bool
 }
 
-func NewChanBatcher[T BatchableTask[R], R any](batchCfg BatchCfg, batchFn BatchFn[T]) *ChanBatcher[T, R] {
-	_, batchCnt := batchCfg()
-	chanBatcher := &ChanBatcher[T, R]{
-		batchCfg: batchCfg,
-		batchFn:  batchFn,
-		taskCh:   make(chan T, 16*batchCnt),
-		flushCh:  make(chan struct{}, 1),
-	}
+// batchItem is a task queued in a ChanBatcher with its priority and the number of retries done.
+type batchItem[T any] struct {
+	task     T
+	priority Priority
+	attempt  int
+}
+
+func NewChanBatcher[T BatchableTask[R], R any](batchCfg BatchCfg, batchFn BatchFn[T],
+	options ...ChanBatcherOption) *ChanBatcher[T, R] {
+	return NewChanBatcherWithCtx[T, R](batchCfg, batchFn.withCtx(), options...)
+}
+
+// NewChanBatcherWithCtx creates a ChanBatcher with a BatchCtxFn.
+func NewChanBatcherWithCtx[T BatchableTask[R], R any](batchCfg BatchCfg, batchFn BatchCtxFn[T],
+	options ...ChanBatcherOption) *ChanBatcher[T, R] {
+	chanBatcher := newChanBatcher[T, R](batchCfg, batchFn, options...)
 	go chanBatcher.worker()
 	return chanBatcher
 }
 
+// newChanBatcher creates a ChanBatcher without starting its worker goroutine.
+func newChanBatcher[T BatchableTask[R], R any](batchCfg BatchCfg, batchFn BatchCtxFn[T],
+	options ...ChanBatcherOption) *ChanBatcher[T, R] {
+	opts := chanBatcherOpts{clock: RealClock}
+	for _, option := range options {
+		option(&opts)
+	}
+	_, batchCnt := batchCfg()
+	return &ChanBatcher[T, R]{
+		batchCfg:      batchCfg,
+		batchFn:       batchFn,
+		observer:      opts.observer,
+		retry:         opts.retry,
+		unresolved:    opts.unresolved,
+		highBatchRate: opts.highBatchRate,
+		clock:         opts.clock,
+		taskCh:        make(chan batchItem[T], 16*batchCnt),
+		retryCh:       make(chan batchItem[T]),
+		flushCh:       make(chan struct{}, 1),
+		workerDone:    make(chan struct{}),
+	}
+}
+
 // Batch submits a BatchableTask to the channel if this chanBatcher hasn't been closed.
 func (b *ChanBatcher[T, R]) Batch(task T) {
+	b.BatchWithPriority(task, PriorityNormal)
+}
+
+// BatchWithPriority submits a BatchableTask with the given priority to the channel if this chanBatcher hasn't been
+// closed.
+func (b *ChanBatcher[T, R]) BatchWithPriority(task T, priority Priority) {
 	if !b.closed.Load() {
-		b.taskCh <- task
+		queueLen := b.queueLen.Add(1)
+		if b.observer != nil {
+			b.observer.OnEnqueue(task.Ctx(), int(queueLen))
+		}
+		b.taskCh <- batchItem[T]{task: task, priority: min(max(priority, PriorityNormal), PriorityHigh)}
 	} else {
 		task.Resolve(*new(R), ErrBatcherClosed)
 	}
@@ -152,97 +290,273 @@ func (b *ChanBatcher[_, _]) Close() {
 	}
 }
 
-// goBatchFn
-func (b *ChanBatcher[T, R]) batchFnWithRecover(tasks []T) {
+// dispatch triggers batchFn with a batch of tasks in a new goroutine, skipping tasks cancelled while lingering.
+func (b *ChanBatcher[T, R]) dispatch(items []batchItem[T], trigger BatchTrigger, lingerStart time.Time) {
+	queueLen := b.queueLen.Add(-int64(len(items)))
+	items = skipCancelled[T, R](items)
+	if len(items) == 0 {
+		return
+	}
+	tasks := make([]T, len(items))
+	for i, item := range items {
+		tasks[i] = item.task
+	}
+	klog.Debugf(tasks[0].Ctx(), "ChanBatcher.worker|%s|%d tasks", trigger, len(tasks))
+	var info *BatchInfo
+	if b.observer != nil {
+		info = &BatchInfo{
+			Trigger:  trigger,
+			Size:     len(tasks),
+			QueueLen: int(queueLen),
+			Wait:     b.clock.Now().Sub(lingerStart),
+			TaskCtxs: make([]context.Context, len(tasks)),
+		}
+		for i, task := range tasks {
+			info.TaskCtxs[i] = task.Ctx()
+		}
+	}
+	go b.batchFnWithRecover(tasks, items, info)
+}
+
+// batchFnWithRecover calls batchFn, notifying the observer if any, and makes sure no task is left unresolved, either by
+// resolving it with an error (if batchFn panicked or per unresolved policy) or by retrying it if retrying is enabled.
+func (b *ChanBatcher[T, R]) batchFnWithRecover(tasks []T, items []batchItem[T], info *BatchInfo) {
+	ctx, start := context.Background(), b.clock.Now()
+	if b.observer != nil {
+		ctx = b.observer.OnDispatch(ctx, info)
+		defer func() { // deferred first to run last, even if the unresolved policy panics
+			b.observer.OnComplete(ctx, info, b.clock.Now().Sub(start))
+		}()
+	}
+	var retryErrs *batchRetryErrs
+	if b.retry != nil {
+		retryErrs = b.interceptRetries(items)
+	}
+	batchCtx, cancel := context.WithCancelCause(ctx)
+	go cancelWhenTasksDone[T, R](batchCtx, cancel, tasks)
 	defer func() {
-		p := recover()
-		if p == nil {
-			return
+		cancel(nil)
+		var panicked error
+		if p := recover(); p != nil {
+			klog.Errorf(context.Background(), "ChanBatcher.goBatchFn|recovered from panic: %v\n%s",
+				p, string(debug.Stack()))
+			panicked = panicErr(p, "batchFn")
+			if b.retry == nil {
+				resolvePanicked[T, R](tasks, panicked)
+			}
+			if b.observer != nil {
+				b.observer.OnPanic(ctx, info, p)
+			}
 		}
-		klog.Errorf(context.Background(), "ChanBatcher.goBatchFn|recovered from panic: %v\n%s",
-			p, string(debug.Stack()))
-		var ret R
-		err, ok := p.(error)
-		if ok {
-			err = errors.Wrap(err, "batchFn panicked")
+		if b.retry != nil {
+			b.retryUnresolved(items, retryErrs, panicked)
 		} else {
-			err = errors.Errorf("batchFn panicked: %v", p)
+			b.resolveUnresolved(tasks)
 		}
-		for _, task := range tasks {
-			if task.IsDone() {
+	}()
+	b.batchFn(batchCtx, tasks)
+}
+
+// skipCancelled resolves and filters out tasks whose contexts have been cancelled.
+func skipCancelled[T BatchableTask[R], R any](items []batchItem[T]) []batchItem[T] {
+	filtered := items[:0]
+	for _, item := range items {
+		if task := item.task; !task.IsDone() {
+			if ctx := task.Ctx(); ctx.Err() != nil {
+				klog.Infof(ctx, "ChanBatcher.worker|skip|task=%v", task)
+				task.Resolve(*new(R), ctx.Err())
 				continue
 			}
-			task.Resolve(ret, err)
 		}
-	}()
-	b.batchFn(tasks)
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// cancelWhenTasksDone cancels a batch context with ErrBatchTasksDone once all tasks have been cancelled or resolved.
+func cancelWhenTasksDone[T BatchableTask[R], R any](ctx context.Context, cancel context.CancelCauseFunc, tasks []T) {
+	for _, task := range tasks {
+		select {
+		case <-task.Done():
+		case <-task.Ctx().Done():
+		case <-ctx.Done():
+			return
+		}
+	}
+	cancel(ErrBatchTasksDone)
+}
+
+// resolvePanicked resolves unresolved tasks with err, wrapping the value recovered from a batchFn panic.
+func resolvePanicked[T BatchableTask[R], R any](tasks []T, err error) {
+	var ret R
+	for _, task := range tasks {
+		if task.IsDone() {
+			continue
+		}
+		task.Resolve(ret, err)
+	}
+}
+
+// panicErr converts a value recovered from a panic of the named function to an error.
+func panicErr(p any, fnName string) error {
+	if err, ok := p.(error); ok {
+		return errors.Wrapf(err, "%s panicked", fnName)
+	}
+	return errors.Errorf("%s panicked: %v", fnName, p)
+}
+
+// resolveUnresolved handles tasks left unresolved after batchFn returns per unresolved policy.
+func (b *ChanBatcher[T, R]) resolveUnresolved(tasks []T) {
+	var unresolved int
+	for _, task := range tasks {
+		if task.IsDone() {
+			continue
+		}
+		unresolved++
+		if b.unresolved != UnresolvedLog {
+			task.Resolve(*new(R), ErrTaskNotResolved)
+		}
+	}
+	if unresolved == 0 {
+		return
+	}
+	klog.Errorf(tasks[0].Ctx(), "ChanBatcher.batchFn|%d/%d tasks not resolved", unresolved, len(tasks))
+	if b.unresolved == UnresolvedPanic {
+		panic(errors.Errorf("ChanBatcher.batchFn|%d/%d tasks not resolved", unresolved, len(tasks)))
+	}
 }
 
 // worker batches up BatchableTask's in taskCh per batchCfg (per at most batchRate ns and at most batchCnt BatchableTask's)
-// and triggers batchFn with each batch.
+// and triggers batchFn with each batch. Tasks already queued in taskCh are taken in before forming batches so that
+// batches are filled with higher priority tasks first.
 func (b *ChanBatcher[T, R]) worker() {
 	defer func() {
+		close(b.workerDone)
 		if p := recover(); p != nil {
 			klog.Errorf(context.Background(), "ChanBatcher.worker|recovered from panic: %v\n%s",
 				p, string(debug.Stack()))
 		}
 	}()
-	var tasks []T
-	batchTimer := time.NewTimer(time.Duration(math.MaxInt64))
-	for {
-		runtime.Gosched() // in case GOMAXPROCS is 1, we need to cooperatively yield
-		select {
-		case <-batchTimer.C:
-			if len(tasks) == 0 {
-				break
+	var lanes [PriorityHigh + 1][]batchItem[T]
+	var lingerStart, lingerEnd time.Time
+	batchTimer := b.clock.NewTimer(time.Duration(math.MaxInt64))
+	batchTimer.Stop()
+	pending := func() int {
+		return len(lanes[PriorityNormal]) + len(lanes[PriorityHigh])
+	}
+	resetTimer := func(ctx context.Context, duration time.Duration) {
+		klog.Debugf(ctx, "ChanBatcher.worker|timer start|duration=%s", duration)
+		if !batchTimer.Stop() {
+			select {
+			case <-batchTimer.C():
+			default:
 			}
-			klog.Debugf(tasks[0].Ctx(), "ChanBatcher.worker|timer|%d tasks", len(tasks))
-			go b.batchFnWithRecover(tasks)
-			tasks = tasks[:0:0]
-		case <-b.flushCh:
-			if len(tasks) == 0 {
-				break
+		}
+		batchTimer.Reset(duration)
+		lingerEnd = b.clock.Now().Add(duration)
+	}
+	add := func(item batchItem[T]) {
+		task := item.task
+		ctx := task.Ctx()
+		if !task.IsDone() {
+			select {
+			case <-ctx.Done():
+				klog.Infof(ctx, "ChanBatcher.worker|skip|task=%v", task)
+				b.queueLen.Add(-1)
+				task.Resolve(*new(R), ctx.Err())
+				return
+			default:
 			}
-			klog.Debugf(tasks[0].Ctx(), "ChanBatcher.worker|flush|%d tasks", len(tasks))
-			go b.batchFnWithRecover(tasks)
-			tasks = tasks[:0:0]
-		case task, ok := <-b.taskCh:
-			if !ok {
-				ctx := context.Background()
-				if len(tasks) > 0 {
-					ctx = tasks[0].Ctx()
-					go b.batchFnWithRecover(tasks)
+		}
+		duration, _ := b.batchCfg()
+		if item.priority == PriorityHigh && b.highBatchRate > 0 {
+			duration = min(duration, b.highBatchRate)
+		}
+		if pending() == 0 {
+			lingerStart = b.clock.Now()
+			resetTimer(ctx, duration)
+		} else if lingerEnd.Sub(b.clock.Now()) > duration {
+			resetTimer(ctx, duration)
+		}
+		lanes[item.priority] = append(lanes[item.priority], item)
+	}
+	takeIn := func(item batchItem[T]) {
+		add(item)
+		for range len(b.taskCh) {
+			select {
+			case item, ok := <-b.taskCh:
+				if !ok {
+					return
 				}
-				klog.Debugf(ctx, "ChanBatcher.worker|closed|%d tasks", len(tasks))
+				add(item)
+			default:
 				return
 			}
-			ctx := task.Ctx()
-			if !task.IsDone() {
-				select {
-				case <-ctx.Done():
-					klog.Infof(ctx, "ChanBatcher.worker|skip|task=%v", task)
-					task.Resolve(*new(R), ctx.Err())
-					continue
-				default:
-				}
+		}
+	}
+	// dispatchBatches dispatches batches of batchCnt tasks, taking higher priority tasks first, until fewer than
+	// minCnt tasks (i.e. batchCnt to leave a partial batch lingering, or 1 to dispatch all) are left pending.
+	dispatchBatches := func(trigger BatchTrigger, minCnt int) {
+		_, batchCnt := b.batchCfg()
+		batchCnt = max(batchCnt, 1)
+		if minCnt > batchCnt {
+			minCnt = batchCnt
+		}
+		if pending() < minCnt || pending() == 0 {
+			return
+		}
+		batchTimer.Stop()
+		for pending() >= minCnt && pending() > 0 {
+			batch := make([]batchItem[T], 0, min(batchCnt, pending()))
+			for priority := PriorityHigh; priority >= PriorityNormal && len(batch) < batchCnt; priority-- {
+				n := min(batchCnt-len(batch), len(lanes[priority]))
+				batch = append(batch, lanes[priority][:n]...)
+				lanes[priority] = lanes[priority][n:]
+			}
+			b.dispatch(batch, trigger, lingerStart)
+		}
+		for priority := range lanes {
+			if len(lanes[priority]) == 0 {
+				lanes[priority] = nil
 			}
-			duration, batchCount := b.batchCfg()
-			if len(tasks) == 0 {
-				klog.Debugf(ctx, "ChanBatcher.worker|timer start|duration=%s", duration)
-				if !batchTimer.Stop() {
-					select {
-					case <-batchTimer.C:
-					default:
-					}
+		}
+		if pending() > 0 {
+			lingerStart = b.clock.Now()
+			duration, _ := b.batchCfg()
+			if len(lanes[PriorityHigh]) > 0 && b.highBatchRate > 0 {
+				duration = min(duration, b.highBatchRate)
+			}
+			resetTimer(context.Background(), duration)
+		}
+	}
+	for {
+		runtime.Gosched() // in case GOMAXPROCS is 1, we need to cooperatively yield
+		select {
+		case <-batchTimer.C():
+			dispatchBatches(BatchTriggerTimer, 1)
+		case <-b.flushCh:
+			select {
+			case item, ok := <-b.taskCh:
+				if ok {
+					takeIn(item)
 				}
-				batchTimer.Reset(duration)
+			default:
 			}
-			tasks = append(tasks, task)
-			if len(tasks) >= batchCount {
-				klog.Debugf(ctx, "ChanBatcher.worker|max|%d tasks", len(tasks))
-				go b.batchFnWithRecover(tasks)
-				tasks = tasks[:0:0]
+			dispatchBatches(BatchTriggerFlush, 1)
+		case item := <-b.retryCh:
+			takeIn(item)
+			dispatchBatches(BatchTriggerMax, math.MaxInt)
+		case item, ok := <-b.taskCh:
+			if !ok {
+				if pending() > 0 {
+					dispatchBatches(BatchTriggerClose, 1)
+				} else {
+					klog.Debugf(context.Background(), "ChanBatcher.worker|closed|0 tasks")
+				}
+				return
 			}
+			takeIn(item)
+			dispatchBatches(BatchTriggerMax, math.MaxInt)
 		}
 	}
 }
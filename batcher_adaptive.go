@@ -0,0 +1,123 @@
+package kutils
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveBatchOpts configures the bounds and the AIMD feedback of an AdaptiveBatchCfg.
+type AdaptiveBatchOpts struct {
+	MinBatchCnt    int           // min batch count, default 1
+	MaxBatchCnt    int           // max batch count, default 100
+	MinBatchRate   time.Duration // min linger time, default 0
+	MaxBatchRate   time.Duration // max linger time, default 10ms
+	TargetLatency  time.Duration // batchFn latency above which batch count is decreased, default 100ms
+	IncreaseStep   int           // additive increase of batch count per batch within TargetLatency, default 1
+	DecreaseFactor float64       // multiplicative decrease of batch count per batch over TargetLatency, default 0.5
+	Clock          Clock         // clock to measure arrival rate and pace decreases, default RealClock
+}
+
+// AdaptiveBatchCfg tunes batch count and linger time of a ChanBatcher from observed batchFn latency and task arrival
+// rate. Batch count grows additively while batchFn latency stays within TargetLatency and shrinks multiplicatively
+// (at most once per TargetLatency) otherwise. Linger time is the expected time to fill a batch at the current arrival
+// rate, bounded by MinBatchRate and MaxBatchRate, or MinBatchRate if too few tasks are expected within MaxBatchRate
+// to make lingering worth it.
+//
+// It must be registered both as the BatchCfg and as a BatchObserver of the ChanBatcher:
+//
+//	adaptive := NewAdaptiveBatchCfg(AdaptiveBatchOpts{MaxBatchCnt: 200})
+//	batcher := NewChanBatcher[T, R](adaptive.BatchCfg, batchFn, WithBatchObserver(adaptive))
+type AdaptiveBatchCfg struct {
+	opts     AdaptiveBatchOpts
+	arrivals atomic.Int64
+
+	mu           sync.Mutex
+	batchCnt     float64
+	arrivalRate  float64 // EWMA of tasks per second
+	lastSample   time.Time
+	lastDecrease time.Time
+}
+
+// adaptiveBatchRateAlpha is the weight of the latest sample in the EWMA of arrival rate.
+const adaptiveBatchRateAlpha = 0.2
+
+func NewAdaptiveBatchCfg(opts AdaptiveBatchOpts) *AdaptiveBatchCfg {
+	if opts.MinBatchCnt <= 0 {
+		opts.MinBatchCnt = 1
+	}
+	if opts.MaxBatchCnt <= 0 {
+		opts.MaxBatchCnt = 100
+	}
+	opts.MaxBatchCnt = max(opts.MaxBatchCnt, opts.MinBatchCnt)
+	if opts.MaxBatchRate <= 0 {
+		opts.MaxBatchRate = 10 * time.Millisecond
+	}
+	opts.MaxBatchRate = max(opts.MaxBatchRate, opts.MinBatchRate)
+	if opts.TargetLatency <= 0 {
+		opts.TargetLatency = 100 * time.Millisecond
+	}
+	if opts.IncreaseStep <= 0 {
+		opts.IncreaseStep = 1
+	}
+	if opts.DecreaseFactor <= 0 || opts.DecreaseFactor >= 1 {
+		opts.DecreaseFactor = 0.5
+	}
+	if opts.Clock == nil {
+		opts.Clock = RealClock
+	}
+	return &AdaptiveBatchCfg{
+		opts:       opts,
+		batchCnt:   float64(opts.MaxBatchCnt),
+		lastSample: opts.Clock.Now(),
+	}
+}
+
+// BatchCfg returns the current linger time and batch count. Its method value can be used as a BatchCfg.
+func (a *AdaptiveBatchCfg) BatchCfg() (batchRate time.Duration, batchCnt int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	batchCnt = int(a.batchCnt)
+	if a.arrivalRate*a.opts.MaxBatchRate.Seconds() < 2 {
+		return a.opts.MinBatchRate, batchCnt
+	}
+	fillTime := time.Duration(float64(batchCnt) / a.arrivalRate * float64(time.Second))
+	return min(max(fillTime, a.opts.MinBatchRate), a.opts.MaxBatchRate), batchCnt
+}
+
+func (a *AdaptiveBatchCfg) OnEnqueue(context.Context, int) {
+	a.arrivals.Add(1)
+}
+
+func (a *AdaptiveBatchCfg) OnDispatch(ctx context.Context, _ *BatchInfo) context.Context {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.opts.Clock.Now()
+	elapsed := now.Sub(a.lastSample).Seconds()
+	if elapsed <= 0 {
+		return ctx
+	}
+	rate := float64(a.arrivals.Swap(0)) / elapsed
+	a.arrivalRate = adaptiveBatchRateAlpha*rate + (1-adaptiveBatchRateAlpha)*a.arrivalRate
+	a.lastSample = now
+	return ctx
+}
+
+func (a *AdaptiveBatchCfg) OnPanic(context.Context, *BatchInfo, any) {}
+
+func (a *AdaptiveBatchCfg) OnComplete(_ context.Context, _ *BatchInfo, duration time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if duration <= a.opts.TargetLatency {
+		a.batchCnt = math.Min(a.batchCnt+float64(a.opts.IncreaseStep), float64(a.opts.MaxBatchCnt))
+		return
+	}
+	now := a.opts.Clock.Now()
+	if now.Sub(a.lastDecrease) < a.opts.TargetLatency {
+		return
+	}
+	a.lastDecrease = now
+	a.batchCnt = math.Max(a.batchCnt*a.opts.DecreaseFactor, float64(a.opts.MinBatchCnt))
+}
@@ -0,0 +1,114 @@
+package kutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveBatchCfg(t *testing.T) {
+	ctx := context.Background()
+	t.Run("defaults", func(t *testing.T) {
+		adaptive := NewAdaptiveBatchCfg(AdaptiveBatchOpts{MinBatchCnt: 10, MaxBatchCnt: 5, DecreaseFactor: 2})
+		assert.Equal(t, AdaptiveBatchOpts{
+			MinBatchCnt:    10,
+			MaxBatchCnt:    10,
+			MaxBatchRate:   10 * time.Millisecond,
+			TargetLatency:  100 * time.Millisecond,
+			IncreaseStep:   1,
+			DecreaseFactor: 0.5,
+			Clock:          RealClock,
+		}, adaptive.opts)
+	})
+
+	t.Run("aimd batch count", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		adaptive := NewAdaptiveBatchCfg(AdaptiveBatchOpts{
+			MinBatchCnt:   4,
+			MaxBatchCnt:   32,
+			MinBatchRate:  time.Millisecond,
+			TargetLatency: 10 * time.Millisecond,
+			IncreaseStep:  2,
+			Clock:         clock,
+		})
+		batchRate, batchCnt := adaptive.BatchCfg()
+		assert.Equal(t, time.Millisecond, batchRate)
+		assert.Equal(t, 32, batchCnt)
+
+		adaptive.OnComplete(ctx, nil, 20*time.Millisecond)
+		_, batchCnt = adaptive.BatchCfg()
+		assert.Equal(t, 16, batchCnt)
+		adaptive.OnComplete(ctx, nil, 20*time.Millisecond) // decreases at most once per TargetLatency
+		_, batchCnt = adaptive.BatchCfg()
+		assert.Equal(t, 16, batchCnt)
+
+		clock.Advance(10 * time.Millisecond)
+		adaptive.OnComplete(ctx, nil, 20*time.Millisecond)
+		clock.Advance(10 * time.Millisecond)
+		adaptive.OnComplete(ctx, nil, 20*time.Millisecond)
+		_, batchCnt = adaptive.BatchCfg()
+		assert.Equal(t, 4, batchCnt)
+
+		adaptive.OnComplete(ctx, nil, 5*time.Millisecond)
+		_, batchCnt = adaptive.BatchCfg()
+		assert.Equal(t, 6, batchCnt)
+		for range 20 {
+			adaptive.OnComplete(ctx, nil, 5*time.Millisecond)
+		}
+		_, batchCnt = adaptive.BatchCfg()
+		assert.Equal(t, 32, batchCnt)
+	})
+
+	t.Run("linger by arrival rate", func(t *testing.T) {
+		adaptive := NewAdaptiveBatchCfg(AdaptiveBatchOpts{
+			MaxBatchCnt:  10,
+			MinBatchRate: time.Millisecond,
+			MaxBatchRate: 100 * time.Millisecond,
+		})
+
+		adaptive.arrivalRate = 10 // 1 task expected per MaxBatchRate: no point lingering
+		batchRate, _ := adaptive.BatchCfg()
+		assert.Equal(t, time.Millisecond, batchRate)
+
+		adaptive.arrivalRate = 50 // fill time 200ms
+		batchRate, _ = adaptive.BatchCfg()
+		assert.Equal(t, 100*time.Millisecond, batchRate)
+
+		adaptive.arrivalRate = 500 // fill time 20ms
+		batchRate, _ = adaptive.BatchCfg()
+		assert.Equal(t, 20*time.Millisecond, batchRate)
+
+		adaptive.arrivalRate = 1e6 // fill time 10µs
+		batchRate, _ = adaptive.BatchCfg()
+		assert.Equal(t, time.Millisecond, batchRate)
+	})
+
+	t.Run("with batcher", func(t *testing.T) {
+		adaptive := NewAdaptiveBatchCfg(AdaptiveBatchOpts{MaxBatchCnt: 4, TargetLatency: time.Millisecond})
+		batcher := NewChanBatcher[*ChanTask[int], int](adaptive.BatchCfg, func(tasks []*ChanTask[int]) {
+			time.Sleep(2 * time.Millisecond)
+			for _, task := range tasks {
+				task.Resolve(len(tasks), nil)
+			}
+		}, WithBatchObserver(adaptive))
+		defer batcher.Close()
+
+		tasks := make([]*ChanTask[int], 4)
+		for i := range tasks {
+			tasks[i] = NewChanTask[int](ctx)
+			batcher.Batch(tasks[i])
+		}
+		for _, task := range tasks {
+			ret, err := task.Result()
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, ret, 4)
+		}
+		assert.Eventually(t, func() bool {
+			_, batchCnt := adaptive.BatchCfg()
+			return batchCnt < 4
+		}, time.Second, time.Millisecond)
+		assert.Positive(t, adaptive.arrivalRate)
+	})
+}
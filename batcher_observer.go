@@ -0,0 +1,74 @@
+package kutils
+
+import (
+	"context"
+	"time"
+)
+
+// BatchTrigger is the reason why a ChanBatcher dispatched a batch.
+type BatchTrigger string
+
+const (
+	BatchTriggerTimer BatchTrigger = "timer" // no more task was queued within batchRate
+	BatchTriggerMax   BatchTrigger = "max"   // batchCnt tasks were queued
+	BatchTriggerFlush BatchTrigger = "flush" // Flush was called
+	BatchTriggerClose BatchTrigger = "close" // Close was called
+)
+
+// BatchInfo describes a batch dispatched by a ChanBatcher.
+type BatchInfo struct {
+	Trigger  BatchTrigger      // why the batch was dispatched
+	Size     int               // number of tasks in the batch
+	QueueLen int               // number of tasks still queued in the batcher after dispatching this batch
+	Wait     time.Duration     // how long the first task of the batch lingered in the batcher
+	TaskCtxs []context.Context // contexts of the tasks in the batch, in the same order as the tasks
+}
+
+// BatchObserver observes tasks and batches of a ChanBatcher, e.g. to export metrics and traces.
+// Hooks are called synchronously by the batcher so they should be cheap and must not block.
+type BatchObserver interface {
+	// OnEnqueue is called when a task is submitted with the number of tasks currently queued in the batcher.
+	OnEnqueue(ctx context.Context, queueLen int)
+	// OnDispatch is called right before batchFn is called for a batch. The returned context is passed to OnPanic
+	// and OnComplete of the same batch.
+	OnDispatch(ctx context.Context, info *BatchInfo) context.Context
+	// OnPanic is called with the recovered value if batchFn panicked.
+	OnPanic(ctx context.Context, info *BatchInfo, p any)
+	// OnComplete is called after batchFn returned or panicked with the duration it took.
+	OnComplete(ctx context.Context, info *BatchInfo, duration time.Duration)
+}
+
+// BatchObservers combines multiple BatchObserver's into one which calls each of them in order.
+func BatchObservers(observers ...BatchObserver) BatchObserver {
+	if len(observers) == 1 {
+		return observers[0]
+	}
+	return batchObservers(observers)
+}
+
+type batchObservers []BatchObserver
+
+func (o batchObservers) OnEnqueue(ctx context.Context, queueLen int) {
+	for _, observer := range o {
+		observer.OnEnqueue(ctx, queueLen)
+	}
+}
+
+func (o batchObservers) OnDispatch(ctx context.Context, info *BatchInfo) context.Context {
+	for _, observer := range o {
+		ctx = observer.OnDispatch(ctx, info)
+	}
+	return ctx
+}
+
+func (o batchObservers) OnPanic(ctx context.Context, info *BatchInfo, p any) {
+	for _, observer := range o {
+		observer.OnPanic(ctx, info, p)
+	}
+}
+
+func (o batchObservers) OnComplete(ctx context.Context, info *BatchInfo, duration time.Duration) {
+	for i := len(o) - 1; i >= 0; i-- {
+		o[i].OnComplete(ctx, info, duration)
+	}
+}
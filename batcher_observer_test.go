@@ -0,0 +1,107 @@
+package kutils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingBatchObserver struct {
+	mu        sync.Mutex
+	enqueued  []int
+	triggers  []BatchTrigger
+	sizes     []int
+	panics    []any
+	completed int
+	doneCh    chan struct{}
+}
+
+func (o *recordingBatchObserver) OnEnqueue(_ context.Context, queueLen int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.enqueued = append(o.enqueued, queueLen)
+}
+
+func (o *recordingBatchObserver) OnDispatch(ctx context.Context, info *BatchInfo) context.Context {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.triggers = append(o.triggers, info.Trigger)
+	o.sizes = append(o.sizes, info.Size)
+	return ctx
+}
+
+func (o *recordingBatchObserver) OnPanic(_ context.Context, _ *BatchInfo, p any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.panics = append(o.panics, p)
+}
+
+func (o *recordingBatchObserver) OnComplete(context.Context, *BatchInfo, time.Duration) {
+	o.mu.Lock()
+	o.completed++
+	o.mu.Unlock()
+	o.doneCh <- struct{}{}
+}
+
+func TestChanBatcher_observer(t *testing.T) {
+	ctx := context.Background()
+	observer := &recordingBatchObserver{doneCh: make(chan struct{}, 8)}
+	batcher := NewChanBatcher[*ChanTask[int], int](func() (time.Duration, int) { return time.Hour, 2 },
+		func(tasks []*ChanTask[int]) {
+			if tasks[0].Ctx().Value("panic") != nil {
+				panic("test panic")
+			}
+			for _, task := range tasks {
+				task.Resolve(len(tasks), nil)
+			}
+		}, WithBatchObserver(observer))
+
+	task0, task1 := NewChanTask[int](ctx), NewChanTask[int](ctx)
+	batcher.Batch(task0)
+	batcher.Batch(task1)
+	_, _ = task1.Result()
+	<-observer.doneCh
+
+	task2 := NewChanTask[int](ctx)
+	batcher.Batch(task2)
+	batcher.Flush()
+	_, _ = task2.Result()
+	<-observer.doneCh
+
+	task3 := NewChanTask[int](context.WithValue(ctx, "panic", true)) // nolint:staticcheck
+	batcher.Batch(task3)
+	batcher.Close()
+	_, err := task3.Result()
+	assert.ErrorContains(t, err, "test panic")
+	<-observer.doneCh
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Len(t, observer.enqueued, 4)
+	assert.Equal(t, []BatchTrigger{BatchTriggerMax, BatchTriggerFlush, BatchTriggerClose}, observer.triggers)
+	assert.Equal(t, []int{2, 1, 1}, observer.sizes)
+	assert.Equal(t, []any{"test panic"}, observer.panics)
+	assert.Equal(t, 3, observer.completed)
+}
+
+func TestChanBatcher_observerUnresolvedPanic(t *testing.T) {
+	observer := &recordingBatchObserver{doneCh: make(chan struct{}, 1)}
+	batcher := NewChanBatcher[*ChanTask[int], int](func() (time.Duration, int) { return time.Hour, 2 },
+		func([]*ChanTask[int]) {}, WithBatchObserver(observer), WithUnresolvedPolicy(UnresolvedPanic))
+	defer batcher.Close()
+
+	task := NewChanTask[int](context.Background())
+	info := &BatchInfo{Trigger: BatchTriggerFlush, Size: 1, TaskCtxs: []context.Context{task.Ctx()}}
+	// called synchronously, as the panic would crash the test in the goroutine dispatch starts
+	assert.Panics(t, func() {
+		batcher.batchFnWithRecover([]*ChanTask[int]{task}, []batchItem[*ChanTask[int]]{{task: task}}, info)
+	})
+	assert.ErrorIs(t, task.Err, ErrTaskNotResolved)
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Equal(t, 1, observer.completed)
+}
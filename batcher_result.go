@@ -0,0 +1,100 @@
+package kutils
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrBatchResultMismatch = errors.New("batch results mismatch requests")
+	ErrBatchResultMissing  = errors.New("batch result missing")
+)
+
+// ReqTask is a ChanTask carrying a request, for use with batch functions returning results per request.
+type ReqTask[Q, R any] struct {
+	ChanTask[R]
+	Req Q
+}
+
+func NewReqTask[Q, R any](ctx context.Context, req Q) *ReqTask[Q, R] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &ReqTask[Q, R]{
+		ChanTask: ChanTask[R]{
+			ctx:  ctx,
+			done: make(chan struct{}),
+		},
+		Req: req,
+	}
+}
+
+// SliceBatchFn creates a BatchCtxFn calling fn with the requests of a batch of ReqTask's, which must return either a
+// result per request in the same order or an error for the whole batch.
+func SliceBatchFn[Q, R any](fn func(ctx context.Context, reqs []Q) ([]R, error)) BatchCtxFn[*ReqTask[Q, R]] {
+	return func(ctx context.Context, tasks []*ReqTask[Q, R]) {
+		reqs := reqTaskReqs(tasks)
+		rets, err := fn(ctx, reqs)
+		if err == nil && len(rets) != len(tasks) {
+			err = errors.WithMessagef(ErrBatchResultMismatch, "%d results for %d requests", len(rets), len(tasks))
+		}
+		if err != nil {
+			resolveReqTasks(tasks, err)
+			return
+		}
+		for i, task := range tasks {
+			task.Resolve(rets[i], nil)
+		}
+	}
+}
+
+// MapBatchFn creates a BatchCtxFn calling fn with the requests of a batch of ReqTask's, which must return either
+// results keyed by keyFn of the requests or an error for the whole batch. Tasks whose request keys are missing from
+// the results are resolved with ErrBatchResultMissing.
+func MapBatchFn[Q any, K comparable, R any](keyFn func(Q) K,
+	fn func(ctx context.Context, reqs []Q) (map[K]R, error)) BatchCtxFn[*ReqTask[Q, R]] {
+	return func(ctx context.Context, tasks []*ReqTask[Q, R]) {
+		reqs := reqTaskReqs(tasks)
+		rets, err := fn(ctx, reqs)
+		if err != nil {
+			resolveReqTasks(tasks, err)
+			return
+		}
+		for _, task := range tasks {
+			if ret, ok := rets[keyFn(task.Req)]; ok {
+				task.Resolve(ret, nil)
+			} else {
+				task.Resolve(ret, ErrBatchResultMissing)
+			}
+		}
+	}
+}
+
+// NewSliceBatcher creates a ChanBatcher of ReqTask's with a batch function per SliceBatchFn.
+func NewSliceBatcher[Q, R any](batchCfg BatchCfg, fn func(ctx context.Context, reqs []Q) ([]R, error),
+	options ...ChanBatcherOption) *ChanBatcher[*ReqTask[Q, R], R] {
+	return NewChanBatcherWithCtx[*ReqTask[Q, R], R](batchCfg, SliceBatchFn(fn), options...)
+}
+
+// NewMapBatcher creates a ChanBatcher of ReqTask's with a batch function per MapBatchFn.
+func NewMapBatcher[Q any, K comparable, R any](batchCfg BatchCfg, keyFn func(Q) K,
+	fn func(ctx context.Context, reqs []Q) (map[K]R, error),
+	options ...ChanBatcherOption) *ChanBatcher[*ReqTask[Q, R], R] {
+	return NewChanBatcherWithCtx[*ReqTask[Q, R], R](batchCfg, MapBatchFn(keyFn, fn), options...)
+}
+
+func reqTaskReqs[Q, R any](tasks []*ReqTask[Q, R]) []Q {
+	reqs := make([]Q, len(tasks))
+	for i, task := range tasks {
+		reqs[i] = task.Req
+	}
+	return reqs
+}
+
+func resolveReqTasks[Q, R any](tasks []*ReqTask[Q, R], err error) {
+	var ret R
+	for _, task := range tasks {
+		task.Resolve(ret, err)
+	}
+}
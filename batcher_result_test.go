@@ -0,0 +1,109 @@
+package kutils
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSliceBatchFn(t *testing.T) {
+	ctx := context.Background()
+	errTest := errors.New("test")
+	tests := []struct {
+		name    string
+		fn      func(context.Context, []int) ([]string, error)
+		want    []string
+		wantErr error
+	}{
+		{
+			"happy",
+			func(_ context.Context, reqs []int) ([]string, error) {
+				return SliceMap(reqs, strconv.Itoa), nil
+			},
+			[]string{"1", "2"},
+			nil,
+		},
+		{
+			"error",
+			func(context.Context, []int) ([]string, error) { return nil, errTest },
+			nil,
+			errTest,
+		},
+		{
+			"mismatch",
+			func(context.Context, []int) ([]string, error) { return []string{"1"}, nil },
+			nil,
+			ErrBatchResultMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := []*ReqTask[int, string]{NewReqTask[int, string](ctx, 1), NewReqTask[int, string](ctx, 2)}
+			SliceBatchFn(tt.fn)(ctx, tasks)
+			for i, task := range tasks {
+				ret, err := task.Result()
+				assert.ErrorIs(t, err, tt.wantErr)
+				if tt.want != nil {
+					assert.Equal(t, tt.want[i], ret)
+				}
+			}
+		})
+	}
+}
+
+func TestMapBatchFn(t *testing.T) {
+	ctx := context.Background()
+	errTest := errors.New("test")
+	tasks := []*ReqTask[int, string]{NewReqTask[int, string](ctx, 1), NewReqTask[int, string](ctx, 2),
+		NewReqTask[int, string](ctx, 1)}
+	MapBatchFn(func(req int) int { return req }, func(_ context.Context, reqs []int) (map[int]string, error) {
+		assert.Equal(t, []int{1, 2, 1}, reqs)
+		return map[int]string{1: "1"}, nil
+	})(ctx, tasks)
+	assert.Equal(t, "1", tasks[0].Ret)
+	assert.ErrorIs(t, tasks[1].Err, ErrBatchResultMissing)
+	assert.Equal(t, "1", tasks[2].Ret)
+
+	tasks = []*ReqTask[int, string]{NewReqTask[int, string](ctx, 1)}
+	MapBatchFn(func(req int) int { return req }, func(context.Context, []int) (map[int]string, error) {
+		return nil, errTest
+	})(ctx, tasks)
+	assert.ErrorIs(t, tasks[0].Err, errTest)
+}
+
+func TestNewSliceBatcher(t *testing.T) {
+	ctx := context.Background()
+	batcher := NewSliceBatcher(func() (time.Duration, int) { return time.Millisecond, 3 },
+		func(_ context.Context, reqs []int) ([]int, error) {
+			return SliceMap(reqs, func(req int) int { return req * req }), nil
+		})
+	defer batcher.Close()
+	tasks := make([]*ReqTask[int, int], 3)
+	for i := range tasks {
+		tasks[i] = NewReqTask[int, int](ctx, i)
+		batcher.Batch(tasks[i])
+	}
+	for i, task := range tasks {
+		ret, err := task.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, i*i, ret)
+	}
+}
+
+func TestNewMapBatcher(t *testing.T) {
+	ctx := context.Background()
+	batcher := NewMapBatcher(func() (time.Duration, int) { return time.Millisecond, 3 },
+		strconv.Itoa, func(_ context.Context, reqs []int) (map[string]int, error) {
+			return Map(reqs, strconv.Itoa, func(req int) int { return -req }), nil
+		})
+	defer batcher.Close()
+	task := NewReqTask[int, int](ctx, 2)
+	batcher.Batch(task)
+	ret, err := task.Result()
+	assert.NoError(t, err)
+	assert.Equal(t, -2, ret)
+}
@@ -0,0 +1,142 @@
+package kutils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+// InterceptableTask is a BatchableTask whose resolution can be intercepted, which allows ChanBatcher to retry tasks
+// resolved with retryable errors before their results become visible to callers.
+type InterceptableTask[R any] interface {
+	BatchableTask[R]
+	// Intercept sets fn to be called on Resolve. If fn returns true, the resolution is dropped and the task stays
+	// unresolved. A nil fn removes the interception.
+	Intercept(fn func(ret R, err error) bool)
+}
+
+// RetryCfg configures retrying of tasks by a ChanBatcher. Tasks left unresolved after batchFn returns (or panics) are
+// re-enqueued, as well as InterceptableTask's resolved with an error satisfying RetryCondition. A task is resolved with
+// its last error (ErrTaskNotResolved if it was left unresolved, or the panic error if batchFn panicked) once it runs out
+// of retries or its Ctx deadline is too close to wait for the next retry.
+type RetryCfg struct {
+	RetryCount       int                  // retry count per task (exponential backoff), default 0
+	RetryWaitTime    time.Duration        // first exponential backoff, default 100ms
+	RetryMaxWaitTime time.Duration        // max exponential backoff, default 2s
+	RetryCondition   func(err error) bool // whether a task resolved with err should be retried, default never
+}
+
+// WithRetry enables retrying of tasks of a ChanBatcher per cfg.
+func WithRetry(cfg RetryCfg) ChanBatcherOption {
+	if cfg.RetryWaitTime <= 0 {
+		cfg.RetryWaitTime = 100 * time.Millisecond
+	}
+	if cfg.RetryMaxWaitTime <= 0 {
+		cfg.RetryMaxWaitTime = 2 * time.Second
+	}
+	return func(opts *chanBatcherOpts) {
+		opts.retry = &cfg
+	}
+}
+
+// backoff returns the wait time before the given retry attempt (starting from 1).
+func (c *RetryCfg) backoff(attempt int) time.Duration {
+	wait := c.RetryWaitTime
+	for i := 1; i < attempt && wait < c.RetryMaxWaitTime; i++ {
+		wait *= 2
+	}
+	return min(wait, c.RetryMaxWaitTime)
+}
+
+// canRetry checks whether a task with the given ctx can be retried after having been attempted attempts times.
+func (c *RetryCfg) canRetry(ctx context.Context, attempts int) bool {
+	if attempts > c.RetryCount || ctx.Err() != nil {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > c.backoff(attempts)
+}
+
+// batchRetryErrs collects errors of task resolutions intercepted for retrying.
+type batchRetryErrs struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// interceptRetries intercepts resolutions of InterceptableTask's in a batch with errors satisfying RetryCondition
+// while they can still be retried.
+func (b *ChanBatcher[T, R]) interceptRetries(items []batchItem[T]) *batchRetryErrs {
+	retryErrs := &batchRetryErrs{errs: make([]error, len(items))}
+	if b.retry.RetryCondition == nil {
+		return retryErrs
+	}
+	for i, item := range items {
+		interceptable, ok := any(item.task).(InterceptableTask[R])
+		if !ok || !b.retry.canRetry(item.task.Ctx(), item.attempt+1) {
+			continue
+		}
+		interceptable.Intercept(func(_ R, err error) bool {
+			if err == nil || !b.retry.RetryCondition(err) {
+				return false
+			}
+			retryErrs.mu.Lock()
+			defer retryErrs.mu.Unlock()
+			retryErrs.errs[i] = err
+			return true
+		})
+	}
+	return retryErrs
+}
+
+// retryUnresolved re-enqueues tasks of a batch that were either intercepted or left unresolved, after removing
+// interceptions set by interceptRetries. Unresolved tasks fail with panicked if batchFn panicked.
+func (b *ChanBatcher[T, R]) retryUnresolved(items []batchItem[T], retryErrs *batchRetryErrs, panicked error) {
+	if b.retry.RetryCondition != nil {
+		for _, item := range items {
+			if interceptable, ok := any(item.task).(InterceptableTask[R]); ok {
+				interceptable.Intercept(nil)
+			}
+		}
+	}
+	retryErrs.mu.Lock()
+	defer retryErrs.mu.Unlock()
+	for i, item := range items {
+		err := retryErrs.errs[i]
+		if err == nil {
+			if item.task.IsDone() {
+				continue
+			}
+			if err = panicked; err == nil {
+				err = ErrTaskNotResolved
+			}
+		}
+		item.attempt++
+		b.scheduleRetry(item, err)
+	}
+}
+
+// scheduleRetry re-enqueues a task for its next attempt after backoff, or resolves it with err if it cannot be retried anymore.
+func (b *ChanBatcher[T, R]) scheduleRetry(item batchItem[T], err error) {
+	task := item.task
+	ctx := task.Ctx()
+	if !b.retry.canRetry(ctx, item.attempt) {
+		task.Resolve(*new(R), err)
+		return
+	}
+	wait := b.retry.backoff(item.attempt)
+	klog.Debugf(ctx, "ChanBatcher.retry|attempt=%d|wait=%s|err=%v", item.attempt, wait, err)
+	b.clock.AfterFunc(wait, func() {
+		b.queueLen.Add(1)
+		select {
+		case b.retryCh <- item:
+		case <-b.workerDone:
+			b.queueLen.Add(-1)
+			task.Resolve(*new(R), err)
+		case <-ctx.Done():
+			b.queueLen.Add(-1)
+			task.Resolve(*new(R), ctx.Err())
+		}
+	})
+}
@@ -0,0 +1,250 @@
+package kutils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanBatcher_retry(t *testing.T) {
+	ctx := context.Background()
+	errRetryable := errors.New("retryable")
+	errFatal := errors.New("fatal")
+	batchCfg := func() (time.Duration, int) { return time.Hour, 1 }
+	retryCfg := RetryCfg{
+		RetryCount:       2,
+		RetryWaitTime:    time.Millisecond,
+		RetryMaxWaitTime: 2 * time.Millisecond,
+		RetryCondition:   func(err error) bool { return errors.Is(err, errRetryable) },
+	}
+	// retryAfter waits for batchFn to have been called n times and to have scheduled its retry, then fires it.
+	retryAfter := func(clock *FakeClock, calls *atomic.Int32, n int32) {
+		require.Eventually(t, func() bool { return calls.Load() == n }, time.Second, time.Millisecond)
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+
+	t.Run("retryable error", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func(tasks []*ChanTask[int]) {
+			call := int(calls.Add(1))
+			for _, task := range tasks {
+				if call < 3 {
+					task.Resolve(call, errRetryable)
+				} else {
+					task.Resolve(call, nil)
+				}
+			}
+		}, WithRetry(retryCfg), WithClock(clock))
+		defer batcher.Close()
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		retryAfter(clock, &calls, 1)
+		retryAfter(clock, &calls, 2)
+		ret, err := task.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 3, ret)
+		assert.EqualValues(t, 3, calls.Load())
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func(tasks []*ChanTask[int]) {
+			calls.Add(1)
+			for _, task := range tasks {
+				task.Resolve(0, errRetryable)
+			}
+		}, WithRetry(retryCfg), WithClock(clock))
+		defer batcher.Close()
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		retryAfter(clock, &calls, 1)
+		retryAfter(clock, &calls, 2)
+		_, err := task.Result()
+		assert.ErrorIs(t, err, errRetryable)
+		assert.EqualValues(t, 3, calls.Load())
+	})
+
+	t.Run("non-retryable error", func(t *testing.T) {
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func(tasks []*ChanTask[int]) {
+			calls.Add(1)
+			for _, task := range tasks {
+				task.Resolve(0, errFatal)
+			}
+		}, WithRetry(retryCfg), WithClock(NewFakeClock(time.Now())))
+		defer batcher.Close()
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		_, err := task.Result()
+		assert.ErrorIs(t, err, errFatal)
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("unresolved", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](func() (time.Duration, int) { return time.Hour, 2 },
+			func(tasks []*ChanTask[int]) {
+				if calls.Add(1) == 1 {
+					tasks[0].Resolve(1, nil)
+					return
+				}
+				for _, task := range tasks {
+					task.Resolve(2, nil)
+				}
+			}, WithRetry(RetryCfg{RetryCount: 1, RetryWaitTime: time.Millisecond}), WithClock(clock))
+		defer batcher.Close()
+
+		task0, task1 := NewChanTask[int](ctx), NewChanTask[int](ctx)
+		batcher.Batch(task0)
+		batcher.Batch(task1)
+		retryAfter(clock, &calls, 1)
+		clock.BlockUntil(1) // the retried task lingers alone
+		clock.Advance(time.Hour)
+		ret, err := task0.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, ret)
+		ret, err = task1.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, ret)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("unresolved exhausted", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func([]*ChanTask[int]) { calls.Add(1) },
+			WithRetry(RetryCfg{RetryCount: 1, RetryWaitTime: time.Millisecond}), WithClock(clock))
+		defer batcher.Close()
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		retryAfter(clock, &calls, 1)
+		_, err := task.Result()
+		assert.ErrorIs(t, err, ErrTaskNotResolved)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func(tasks []*ChanTask[int]) {
+			if calls.Add(1) == 1 {
+				panic(errRetryable)
+			}
+			for _, task := range tasks {
+				task.Resolve(2, nil)
+			}
+		}, WithRetry(retryCfg), WithClock(clock))
+		defer batcher.Close()
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		retryAfter(clock, &calls, 1)
+		ret, err := task.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, ret)
+	})
+
+	t.Run("non-retryable panic", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func(tasks []*ChanTask[int]) {
+			if calls.Add(1) == 1 {
+				panic("boom")
+			}
+			for _, task := range tasks {
+				task.Resolve(2, nil)
+			}
+		}, WithRetry(retryCfg), WithClock(clock))
+		defer batcher.Close()
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		retryAfter(clock, &calls, 1)
+		ret, err := task.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, ret)
+	})
+
+	t.Run("panic exhausted", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func([]*ChanTask[int]) {
+			calls.Add(1)
+			panic("boom")
+		}, WithRetry(RetryCfg{RetryCount: 1, RetryWaitTime: time.Millisecond}), WithClock(clock))
+		defer batcher.Close()
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		retryAfter(clock, &calls, 1)
+		_, err := task.Result()
+		assert.ErrorContains(t, err, "boom")
+		assert.NotErrorIs(t, err, ErrTaskNotResolved)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func(tasks []*ChanTask[int]) {
+			calls.Add(1)
+			for _, task := range tasks {
+				task.Resolve(0, errRetryable)
+			}
+		}, WithRetry(RetryCfg{RetryCount: 5, RetryWaitTime: time.Second, RetryCondition: retryCfg.RetryCondition}),
+			WithClock(NewFakeClock(time.Now())))
+		defer batcher.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		_, err := task.Result()
+		assert.ErrorIs(t, err, errRetryable)
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("closed while waiting", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var calls atomic.Int32
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, func(tasks []*ChanTask[int]) {
+			calls.Add(1)
+			for _, task := range tasks {
+				task.Resolve(0, errRetryable)
+			}
+		}, WithRetry(RetryCfg{RetryCount: 1, RetryWaitTime: 10 * time.Millisecond,
+			RetryCondition: retryCfg.RetryCondition}), WithClock(clock))
+
+		task := NewChanTask[int](ctx)
+		batcher.Batch(task)
+		require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		clock.BlockUntil(1)
+		batcher.Close()
+		<-batcher.workerDone
+		clock.Advance(10 * time.Millisecond)
+		_, err := task.Result()
+		assert.ErrorIs(t, err, errRetryable)
+		assert.EqualValues(t, 1, calls.Load())
+	})
+}
+
+func TestRetryCfg_backoff(t *testing.T) {
+	cfg := RetryCfg{RetryWaitTime: 100 * time.Millisecond, RetryMaxWaitTime: time.Second}
+	assert.Equal(t, 100*time.Millisecond, cfg.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, cfg.backoff(2))
+	assert.Equal(t, 800*time.Millisecond, cfg.backoff(4))
+	assert.Equal(t, time.Second, cfg.backoff(5))
+	assert.Equal(t, time.Second, cfg.backoff(100))
+}
@@ -0,0 +1,138 @@
+package kutils
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+// StreamTask is a BatchableTask whose results are streamed to the caller as they arrive, e.g. for batched
+// subscriptions or paginated backends. The batch function delivers partial results with Send and ends the stream with
+// Resolve, while the caller consumes them with Chan, Results or Result. Sends block while the stream buffer is full,
+// so the caller must consume the stream (or cancel the task's Ctx) to not hold up the whole batch.
+type StreamTask[R any] struct {
+	ctx     context.Context
+	ch      chan R
+	ending  chan struct{}
+	endOnce sync.Once
+	mu      sync.RWMutex // held for reading by Send and for writing by Resolve to close ch
+	done    chan struct{}
+	Err     error
+}
+
+func NewStreamTask[R any](ctx context.Context, bufSize int) *StreamTask[R] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &StreamTask[R]{
+		ctx:    ctx,
+		ch:     make(chan R, max(bufSize, 0)),
+		ending: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func (s *StreamTask[R]) Ctx() context.Context {
+	return s.ctx
+}
+
+// Done signals if this task was already resolved, i.e. no more result is to be sent. Results already sent may still
+// be buffered.
+func (s *StreamTask[R]) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *StreamTask[R]) IsDone() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Send sends a partial result to the caller, blocking while the stream buffer is full. It returns false if the result
+// was not sent because the task was already resolved or its Ctx is done.
+func (s *StreamTask[R]) Send(ret R) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	select {
+	case <-s.ending:
+		return false
+	default:
+	}
+	select {
+	case s.ch <- ret:
+		return true
+	case <-s.ending:
+		return false
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// Resolve sends the remaining results then ends the stream with err.
+func (s *StreamTask[R]) Resolve(rets []R, err error) {
+	for _, ret := range rets {
+		if !s.Send(ret) {
+			break
+		}
+	}
+	resolved := false
+	s.endOnce.Do(func() {
+		close(s.ending)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.Err = err
+		close(s.ch)
+		close(s.done)
+		resolved = true
+	})
+	if !resolved {
+		klog.Errorf(s.ctx, "StreamTask.Resolve|called twice, ignored|s.Err=%v|rets=%v,err=%v", s.Err, rets, err)
+	}
+}
+
+// Chan returns the channel of results, closed once the stream ends. Err is then set to the error the stream ended
+// with.
+func (s *StreamTask[R]) Chan() <-chan R {
+	return s.ch
+}
+
+// Results iterates over streamed results. It ends with a zero result and an error if the stream ends with an error or
+// the task's Ctx is done first.
+func (s *StreamTask[R]) Results() iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		for {
+			select {
+			case ret, ok := <-s.ch:
+				if !ok {
+					if s.Err != nil {
+						yield(*new(R), s.Err)
+					}
+					return
+				}
+				if !yield(ret, nil) {
+					return
+				}
+			case <-s.ctx.Done():
+				yield(*new(R), s.ctx.Err())
+				return
+			}
+		}
+	}
+}
+
+// Result blocks until the stream ends and returns the results not consumed yet and the error the stream ended with.
+func (s *StreamTask[R]) Result() ([]R, error) {
+	var rets []R
+	for ret, err := range s.Results() {
+		if err != nil {
+			return rets, err
+		}
+		rets = append(rets, ret)
+	}
+	return rets, nil
+}
@@ -0,0 +1,78 @@
+package kutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamTask(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("batch", func(t *testing.T) {
+		errPage := errors.New("page error")
+		batcher := NewChanBatcher[*StreamTask[int], []int](func() (time.Duration, int) { return time.Hour, 2 },
+			func(tasks []*StreamTask[int]) {
+				for page := range 3 {
+					for i, task := range tasks {
+						task.Send(10*i + page)
+					}
+				}
+				tasks[0].Resolve([]int{3}, nil)
+				tasks[1].Resolve(nil, errPage)
+			})
+		defer batcher.Close()
+
+		task0, task1 := NewStreamTask[int](ctx, 0), NewStreamTask[int](ctx, 3)
+		batcher.Batch(task0)
+		batcher.Batch(task1)
+
+		var rets []int
+		for ret := range task0.Chan() {
+			rets = append(rets, ret)
+		}
+		assert.Equal(t, []int{0, 1, 2, 3}, rets)
+		assert.NoError(t, task0.Err)
+		assert.True(t, task0.IsDone())
+
+		rets, err := task1.Result()
+		assert.Equal(t, []int{10, 11, 12}, rets)
+		assert.ErrorIs(t, err, errPage)
+	})
+
+	t.Run("results", func(t *testing.T) {
+		task := NewStreamTask[int](ctx, 3)
+		assert.True(t, task.Send(0))
+		assert.True(t, task.Send(1))
+		task.Resolve([]int{2}, nil)
+		assert.False(t, task.Send(3))
+		task.Resolve(nil, nil)
+
+		var rets []int
+		for ret, err := range task.Results() {
+			assert.NoError(t, err)
+			rets = append(rets, ret)
+			if ret == 1 {
+				break
+			}
+		}
+		assert.Equal(t, []int{0, 1}, rets)
+		rets, err := task.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2}, rets)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		task := NewStreamTask[int](ctx, 0)
+		sent := make(chan bool)
+		go func() { sent <- task.Send(0) }()
+		cancel()
+		assert.False(t, <-sent)
+		_, err := task.Result()
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
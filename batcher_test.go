@@ -2,7 +2,6 @@ package kutils
 
 import (
 	"context"
-	"runtime"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -16,16 +15,17 @@ import (
 func TestChanBatcher(t *testing.T) {
 	ctx := context.Background()
 	batchRate := 10 * time.Millisecond
+	clock := NewFakeClock(time.Now())
 	batchFn := func(_ []*ChanTask[time.Duration]) {}
 	batcher := NewChanBatcher[*ChanTask[time.Duration], time.Duration](func() (time.Duration, int) {
 		return batchRate, 2
-	}, func(tasks []*ChanTask[time.Duration]) { batchFn(tasks) })
+	}, func(tasks []*ChanTask[time.Duration]) { batchFn(tasks) }, WithClock(clock))
 	var cnt atomic.Uint32
 	var start time.Time
 	batchFn = func(tasks []*ChanTask[time.Duration]) {
 		cnt.Add(1)
 		for _, task := range tasks {
-			task.Resolve(time.Since(start), nil)
+			task.Resolve(clock.Now().Sub(start), nil)
 		}
 	}
 	task0 := NewChanTask[time.Duration](ctx)
@@ -35,7 +35,7 @@ func TestChanBatcher(t *testing.T) {
 
 	t.Run("happy", func(t *testing.T) {
 		t.Run("trigger max", func(t *testing.T) {
-			start = time.Now()
+			start = clock.Now()
 			batcher.Batch(task0)
 			batcher.Batch(task1)
 			_, _ = task0.Result()
@@ -45,26 +45,28 @@ func TestChanBatcher(t *testing.T) {
 			ret, err := task1.Result()
 			assert.NoError(t, err)
 			assert.Less(t, ret, batchRate)
-			time.Sleep(batchRate * 11 / 10)
-			runtime.Gosched()
 		})
 
 		t.Run("trigger timer after blocked by .Result()", func(t *testing.T) {
-			start = time.Now()
+			start = clock.Now()
 			batcher.Batch(task2)
+			clock.BlockUntil(1)
+			clock.Advance(batchRate - 1)
 			assert.False(t, task2.IsDone())
+			clock.Advance(1)
 			ret, err := task2.Result()
 			assert.True(t, task2.IsDone())
 			assert.EqualValues(t, 2, cnt.Load())
 			assert.Equal(t, task2.Err, err)
 			assert.NoError(t, task2.Err)
 			assert.Equal(t, task2.Ret, ret)
-			assert.Greater(t, ret, batchRate)
+			assert.Equal(t, batchRate, ret)
 		})
 
 		t.Run("trigger flush", func(t *testing.T) {
-			start = time.Now()
+			start = clock.Now()
 			batcher.Batch(task3)
+			clock.BlockUntil(1)
 			assert.False(t, task3.IsDone())
 			batcher.Flush()
 			batcher.Flush()
@@ -136,7 +138,7 @@ func TestChanBatcher(t *testing.T) {
 		assert.ErrorIs(t, task0.Err, panicErr)
 		assert.ErrorIs(t, task1.Err, panicErr)
 
-		start = time.Now()
+		start = clock.Now()
 		batchFn = oldBatchFn
 		task2 = NewChanTask[time.Duration](nil) // nolint:staticcheck
 		batcher.Batch(task2)
@@ -168,3 +170,143 @@ func TestChanBatcher(t *testing.T) {
 			nil).Batch(&ChanTask[int]{})
 	})
 }
+
+func TestChanBatcher_unresolved(t *testing.T) {
+	ctx := context.Background()
+	batchCfg := func() (time.Duration, int) { return time.Millisecond, 2 }
+	resolveFirst := func(tasks []*ChanTask[int]) { tasks[0].Resolve(1, nil) }
+
+	t.Run("error", func(t *testing.T) {
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, resolveFirst)
+		defer batcher.Close()
+		task0, task1 := NewChanTask[int](ctx), NewChanTask[int](ctx)
+		batcher.Batch(task0)
+		batcher.Batch(task1)
+		ret, err := task0.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, ret)
+		_, err = task1.Result()
+		assert.ErrorIs(t, err, ErrTaskNotResolved)
+	})
+
+	t.Run("log", func(t *testing.T) {
+		batcher := NewChanBatcher[*ChanTask[int], int](batchCfg, resolveFirst, WithUnresolvedPolicy(UnresolvedLog))
+		defer batcher.Close()
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		task0, task1 := NewChanTask[int](ctx), NewChanTask[int](ctx)
+		batcher.Batch(task0)
+		batcher.Batch(task1)
+		_, err := task0.Result()
+		assert.NoError(t, err)
+		_, err = task1.Result()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		batcher := &ChanBatcher[*ChanTask[int], int]{unresolved: UnresolvedPanic}
+		task0, task1 := NewChanTask[int](ctx), NewChanTask[int](ctx)
+		task0.Resolve(1, nil)
+		assert.Panics(t, func() { batcher.resolveUnresolved([]*ChanTask[int]{task0, task1}) })
+		assert.ErrorIs(t, task1.Err, ErrTaskNotResolved)
+	})
+}
+
+func TestChanBatcher_batchCtx(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cancelled when tasks done", func(t *testing.T) {
+		causeCh := make(chan error, 1)
+		batcher := NewChanBatcherWithCtx[*ChanTask[int], int](func() (time.Duration, int) { return time.Hour, 2 },
+			func(ctx context.Context, tasks []*ChanTask[int]) {
+				tasks[0].Resolve(0, nil)
+				<-ctx.Done()
+				causeCh <- context.Cause(ctx)
+				tasks[1].Resolve(1, nil)
+			})
+		defer batcher.Close()
+		ctx1, cancel1 := context.WithCancel(ctx)
+		task0, task1 := NewChanTask[int](ctx), NewChanTask[int](ctx1)
+		batcher.Batch(task0)
+		batcher.Batch(task1)
+		_, _ = task0.Result()
+		cancel1()
+		assert.ErrorIs(t, <-causeCh, ErrBatchTasksDone)
+	})
+
+	t.Run("skip tasks cancelled while lingering", func(t *testing.T) {
+		batchCh := make(chan []*ChanTask[int], 1)
+		clock := NewFakeClock(time.Now())
+		batcher := newChanBatcher[*ChanTask[int], int](func() (time.Duration, int) { return 20 * time.Millisecond, 3 },
+			BatchFn[*ChanTask[int]](func(tasks []*ChanTask[int]) {
+				for _, task := range tasks {
+					task.Resolve(1, nil)
+				}
+				batchCh <- tasks
+			}).withCtx(), WithClock(clock))
+		ctx0, cancel0 := context.WithCancel(ctx)
+		task0, task1 := NewChanTask[int](ctx0), NewChanTask[int](ctx)
+		batcher.Batch(task0)
+		batcher.Batch(task1)
+		go batcher.worker()
+		defer batcher.Close()
+		clock.BlockUntil(1)
+		clock.Advance(5 * time.Millisecond)
+		cancel0()
+		clock.Advance(15 * time.Millisecond)
+		assert.Equal(t, []*ChanTask[int]{task1}, <-batchCh)
+		_, err := task0.Result()
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestChanBatcher_priority(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fill high priority first", func(t *testing.T) {
+		batchCh := make(chan []*ChanTask[int], 3)
+		batcher := newChanBatcher[*ChanTask[int], int](func() (time.Duration, int) { return time.Hour, 2 },
+			BatchFn[*ChanTask[int]](func(tasks []*ChanTask[int]) {
+				for _, task := range tasks {
+					task.Resolve(0, nil)
+				}
+				batchCh <- tasks
+			}).withCtx())
+		tasks := make([]*ChanTask[int], 5)
+		for i := range tasks {
+			tasks[i] = NewChanTask[int](ctx)
+			priority := PriorityNormal
+			if i%2 == 1 {
+				priority = PriorityHigh
+			}
+			batcher.BatchWithPriority(tasks[i], priority)
+		}
+		go batcher.worker()
+		defer batcher.Close()
+
+		assert.ElementsMatch(t, [][]*ChanTask[int]{{tasks[1], tasks[3]}, {tasks[0], tasks[2]}},
+			[][]*ChanTask[int]{<-batchCh, <-batchCh})
+		batcher.Flush()
+		assert.Equal(t, []*ChanTask[int]{tasks[4]}, <-batchCh)
+	})
+
+	t.Run("high priority batch rate", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		batcher := newChanBatcher[*ChanTask[int], int](func() (time.Duration, int) { return time.Hour, 10 },
+			BatchFn[*ChanTask[int]](func(tasks []*ChanTask[int]) {
+				for _, task := range tasks {
+					task.Resolve(len(tasks), nil)
+				}
+			}).withCtx(), WithHighPriorityBatchRate(time.Millisecond), WithClock(clock))
+		task0, task1 := NewChanTask[int](ctx), NewChanTask[int](ctx)
+		batcher.BatchWithPriority(task1, PriorityHigh)
+		batcher.Batch(task0)
+		go batcher.worker()
+		defer batcher.Close()
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond)
+		ret, err := task0.Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, ret)
+	})
+}
@@ -0,0 +1,172 @@
+package kutils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts time so that time-dependent logic can be tested deterministically with a FakeClock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer abstracts time.Timer, see Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// RealClock is the Clock backed by package time.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{timer: time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{timer: time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
+
+// FakeClock is a Clock for tests whose time only moves forward with Advance, which fires timers as they come due.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	pending map[*fakeTimer]struct{}
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{
+		now:     now,
+		pending: make(map[*fakeTimer]struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	return c.newTimer(d, nil)
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.newTimer(d, f)
+}
+
+func (c *FakeClock) newTimer(d time.Duration, f func()) *fakeTimer {
+	t := &fakeTimer{
+		clock: c,
+		c:     make(chan time.Time, 1),
+		fn:    f,
+	}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing timers due by then in order of their due time.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for t := range c.pending {
+			if !t.when.After(end) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		delete(c.pending, next)
+		c.now = next.when
+		next.fire(c.now)
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// BlockUntil blocks until at least n timers are pending, e.g. to wait for a goroutine to arm its timer before
+// advancing the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.pending) < n {
+		c.cond.Wait()
+	}
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	c     chan time.Time
+	fn    func()
+	when  time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, pending := t.clock.pending[t]
+	delete(t.clock.pending, t)
+	return pending
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, pending := t.clock.pending[t]
+	t.when = t.clock.now.Add(d)
+	if d <= 0 {
+		delete(t.clock.pending, t)
+		t.fire(t.clock.now)
+		return pending
+	}
+	t.clock.pending[t] = struct{}{}
+	t.clock.cond.Broadcast()
+	return pending
+}
+
+// fire fires the timer, which must not be pending anymore. It must be called with the clock's lock held.
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+}
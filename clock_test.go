@@ -0,0 +1,50 @@
+package kutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Now()
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	timer := clock.NewTimer(10 * time.Millisecond)
+	fired := make(chan time.Time, 1)
+	clock.AfterFunc(5*time.Millisecond, func() { fired <- clock.Now() })
+	clock.BlockUntil(2)
+
+	clock.Advance(5 * time.Millisecond)
+	assert.Equal(t, start.Add(5*time.Millisecond), <-fired)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(10 * time.Millisecond)
+	assert.Equal(t, start.Add(10*time.Millisecond), <-timer.C())
+	assert.Equal(t, start.Add(15*time.Millisecond), clock.Now())
+	assert.False(t, timer.Stop())
+
+	assert.False(t, timer.Reset(time.Millisecond))
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	timer := RealClock.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.False(t, timer.Stop())
+	fired := make(chan struct{})
+	RealClock.AfterFunc(time.Millisecond, func() { close(fired) })
+	<-fired
+}
@@ -0,0 +1,148 @@
+package kutils
+
+import (
+	"golang.org/x/exp/constraints"
+)
+
+// Reducer aggregates elements of a group into a value of type A: Init creates the aggregate of the first element of the
+// group and Reduce folds each subsequent element into the aggregate.
+type Reducer[T, A any] struct {
+	Init   func(elem T) A
+	Reduce func(acc A, elem T) A
+}
+
+// GroupBy groups elements of lst by keyFn like MapKeyMulti, but aggregates each group with reducer, e.g.
+// GroupBy(pools, Pool.Exchange, Sum(Pool.Tvl)).
+func GroupBy[T any, K comparable, A any](lst []T, keyFn func(T) K, reducer Reducer[T, A]) map[K]A {
+	res := make(map[K]A)
+	for _, elem := range lst {
+		key := keyFn(elem)
+		if acc, ok := res[key]; ok {
+			res[key] = reducer.Reduce(acc, elem)
+		} else {
+			res[key] = reducer.Init(elem)
+		}
+	}
+	return res
+}
+
+// Sum is a Reducer summing valFn of elements.
+func Sum[T any, N constraints.Integer | constraints.Float](valFn func(T) N) Reducer[T, N] {
+	return Reducer[T, N]{
+		Init:   valFn,
+		Reduce: func(acc N, elem T) N { return acc + valFn(elem) },
+	}
+}
+
+// Count is a Reducer counting elements.
+func Count[T any]() Reducer[T, int] {
+	return Reducer[T, int]{
+		Init:   func(T) int { return 1 },
+		Reduce: func(acc int, _ T) int { return acc + 1 },
+	}
+}
+
+// MinBy is a Reducer keeping the first element with the smallest valFn.
+func MinBy[T any, O constraints.Ordered](valFn func(T) O) Reducer[T, T] {
+	return Reducer[T, T]{
+		Init: func(elem T) T { return elem },
+		Reduce: func(acc T, elem T) T {
+			if valFn(elem) < valFn(acc) {
+				return elem
+			}
+			return acc
+		},
+	}
+}
+
+// MaxBy is a Reducer keeping the first element with the largest valFn.
+func MaxBy[T any, O constraints.Ordered](valFn func(T) O) Reducer[T, T] {
+	return Reducer[T, T]{
+		Init: func(elem T) T { return elem },
+		Reduce: func(acc T, elem T) T {
+			if valFn(elem) > valFn(acc) {
+				return elem
+			}
+			return acc
+		},
+	}
+}
+
+// Fold is a Reducer folding elements with fn starting from init.
+func Fold[T, A any](init A, fn func(acc A, elem T) A) Reducer[T, A] {
+	return Reducer[T, A]{
+		Init:   func(elem T) A { return fn(init, elem) },
+		Reduce: fn,
+	}
+}
+
+// Reduce folds elements of lst with fn starting from init.
+func Reduce[T, A any](lst []T, init A, fn func(acc A, elem T) A) A {
+	acc := init
+	for _, elem := range lst {
+		acc = fn(acc, elem)
+	}
+	return acc
+}
+
+// Partition splits lst into elements satisfying pred and elements not satisfying it, keeping their order.
+func Partition[T any](lst []T, pred func(T) bool) (matched, unmatched []T) {
+	for _, elem := range lst {
+		if pred(elem) {
+			matched = append(matched, elem)
+		} else {
+			unmatched = append(unmatched, elem)
+		}
+	}
+	return matched, unmatched
+}
+
+// Pair holds 2 values, e.g. as zipped by Zip.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip pairs up elements of as and bs at the same index, up to the length of the shorter one.
+func Zip[A, B any](as []A, bs []B) []Pair[A, B] {
+	ret := make([]Pair[A, B], min(len(as), len(bs)))
+	for i := range ret {
+		ret[i] = Pair[A, B]{First: as[i], Second: bs[i]}
+	}
+	return ret
+}
+
+// Unzip splits pairs into their first and second values.
+func Unzip[A, B any](pairs []Pair[A, B]) ([]A, []B) {
+	as, bs := make([]A, len(pairs)), make([]B, len(pairs))
+	for i, pair := range pairs {
+		as[i], bs[i] = pair.First, pair.Second
+	}
+	return as, bs
+}
+
+// Flatten concatenates lsts into a single slice.
+func Flatten[T any](lsts [][]T) []T {
+	var n int
+	for _, lst := range lsts {
+		n += len(lst)
+	}
+	ret := make([]T, 0, n)
+	for _, lst := range lsts {
+		ret = append(ret, lst...)
+	}
+	return ret
+}
+
+// Window returns the sliding windows of size consecutive elements of lst. Like Chunk, windows share the backing array
+// of lst.
+func Window[T any](lst []T, size int) [][]T {
+	if size < 1 || size > len(lst) {
+		return nil
+	}
+	windows := make([][]T, len(lst)-size+1)
+	for i := range windows {
+		windows[i] = lst[i : i+size : i+size]
+	}
+	return windows
+}
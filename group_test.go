@@ -0,0 +1,63 @@
+package kutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupBy(t *testing.T) {
+	type pool struct {
+		exchange string
+		tvl      float64
+	}
+	pools := []pool{{"uni", 1}, {"curve", 5}, {"uni", 3}, {"uni", 2}, {"curve", 5}}
+	exchange := func(p pool) string { return p.exchange }
+	tvl := func(p pool) float64 { return p.tvl }
+
+	assert.Equal(t, map[string]float64{"uni": 6, "curve": 10}, GroupBy(pools, exchange, Sum(tvl)))
+	assert.Equal(t, map[string]int{"uni": 3, "curve": 2}, GroupBy(pools, exchange, Count[pool]()))
+	assert.Equal(t, map[string]pool{"uni": {"uni", 1}, "curve": {"curve", 5}}, GroupBy(pools, exchange, MinBy(tvl)))
+	assert.Equal(t, map[string]pool{"uni": {"uni", 3}, "curve": {"curve", 5}}, GroupBy(pools, exchange, MaxBy(tvl)))
+	assert.Equal(t, map[string][]float64{"uni": {1, 3, 2}, "curve": {5, 5}}, GroupBy(pools, exchange,
+		Fold(nil, func(acc []float64, p pool) []float64 { return append(acc, p.tvl) })))
+	assert.Empty(t, GroupBy(nil, exchange, Count[pool]()))
+}
+
+func TestReduce(t *testing.T) {
+	assert.Equal(t, 10, Reduce([]int{1, 2, 3, 4}, 0, func(acc, i int) int { return acc + i }))
+	assert.Equal(t, "abc", Reduce([]string{"a", "b", "c"}, "", func(acc, s string) string { return acc + s }))
+	assert.Equal(t, 1, Reduce(nil, 1, func(acc, i int) int { return acc * i }))
+}
+
+func TestPartition(t *testing.T) {
+	matched, unmatched := Partition([]int{1, 2, 3, 4, 5}, func(i int) bool { return i%2 == 0 })
+	assert.Equal(t, []int{2, 4}, matched)
+	assert.Equal(t, []int{1, 3, 5}, unmatched)
+}
+
+func TestZip(t *testing.T) {
+	pairs := Zip([]int{1, 2, 3}, []string{"a", "b"})
+	assert.Equal(t, []Pair[int, string]{{1, "a"}, {2, "b"}}, pairs)
+	as, bs := Unzip(pairs)
+	assert.Equal(t, []int{1, 2}, as)
+	assert.Equal(t, []string{"a", "b"}, bs)
+}
+
+func TestFlatten(t *testing.T) {
+	assert.Equal(t, []int{1, 2, 3, 4}, Flatten([][]int{{1}, nil, {2, 3}, {4}}))
+	assert.Empty(t, Flatten[int](nil))
+	assert.Equal(t, []string{"a", "b", "c"}, Flatten([][]string{strings.Split("a,b", ","), {"c"}}))
+}
+
+func TestWindow(t *testing.T) {
+	lst := []int{1, 2, 3, 4}
+	assert.Equal(t, [][]int{{1, 2}, {2, 3}, {3, 4}}, Window(lst, 2))
+	assert.Equal(t, [][]int{{1, 2, 3, 4}}, Window(lst, 4))
+	assert.Nil(t, Window(lst, 5))
+	assert.Nil(t, Window(lst, 0))
+	windows := Window(lst, 2)
+	windows[0] = append(windows[0], 5)
+	assert.Equal(t, []int{1, 2, 3, 4}, lst)
+}
@@ -3,32 +3,74 @@ package kutils
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/hashicorp/go-retryablehttp"
-	"golang.org/x/net/http2"
+	"github.com/pkg/errors"
 
 	"github.com/KyberNetwork/kutils/internal/json"
+	"github.com/KyberNetwork/kutils/klog"
 )
 
 // HttpCfg is the resty http client configs
 type HttpCfg struct {
 	HttpClient          *http.Client  `json:"-"`
 	BaseUrl             string        // client's base url for all methods
+	BaseUrls            []string      // base urls of endpoints to balance requests over, overriding BaseUrl
 	Headers             http.Header   // default headers
 	Timeout             time.Duration // request timeout, see http.Client's Timeout
-	MaxIdleConns        int           // max idle connections for all hosts, default 100
-	MaxIdleConnsPerHost int           // max idle connections per host, default GOMAXPROCS+1
-	MaxConnsPerHost     int           // max total connections per host, default 0 (unlimited)
-	UseH2c              bool          // whether to use http2 h2c, default false
+	MaxIdleConns        int           // max idle connections for all hosts, default 100, ignored by h2c
+	MaxIdleConnsPerHost int           // max idle connections per host, default GOMAXPROCS+1, ignored by h2c
+	MaxConnsPerHost     int           // max total connections per host, default 0 (unlimited), ignored by h2c
+	TLS                 *TLSCfg       // TLS configs (CAs, client certificate, min version...), default nil (Go defaults)
+	Proxy               string        // proxy url, default from HTTP_PROXY/HTTPS_PROXY env vars, h2c unsupported
+	NoProxy             string        // comma-separated hosts not to proxy, default from NO_PROXY env var, h2c unsupported
+	DialTimeout         time.Duration // max time to establish a connection, default 30s
+	KeepAlive           time.Duration // interval of TCP keep-alive probes, default 30s
+	TLSHandshakeTimeout time.Duration // max time of TLS handshakes, default 10s
+	IdleConnTimeout     time.Duration // max time idle connections are kept, default 90s
+	Protocol            HttpProtocol  // protocol mode, "http1", "h2" or "h2c", default "h2"
+	H2ReadIdleTimeout   time.Duration // idle time of HTTP/2 connections before health check pings, default 0 (no ping)
+	H2PingTimeout       time.Duration // timeout of HTTP/2 health check pings before closing connections, default 15s
+	H2StrictMaxStreams  bool          // whether to wait for streams of HTTP/2 connections instead of opening more
+	UseH2c              bool          // whether to use http2 h2c, default false. Deprecated: use Protocol h2c instead
 	RetryCount          int           // retry count (exponential backoff), default 0
 	RetryWaitTime       time.Duration // first exponential backoff, default 100ms
-	RetryMaxWaitTime    time.Duration // max exponential backoff, default 2s
-	Debug               bool          // whether to log requests and responses
+	RetryMaxWaitTime    time.Duration // max exponential backoff and Retry-After wait, default 2s
+	RetryJitter         RetryJitter   // jitter strategy of exponential backoff, default RetryJitterDefault
+	RetryMaxTotalTime   time.Duration // max total time of a request until its last retry, default 0 (unlimited)
+	RetryMethods        []string      // methods retried, default idempotent ones; others need an Idempotency-Key header
+	GenIdempotencyKey   bool          // whether to add an Idempotency-Key header to requests of other methods, retrying them
+	Debug               bool          // whether to dump requests and responses with resty's logger, see Log instead
+
+	CircuitBreaker        *CircuitBreakerCfg // per-host circuit breaker, default nil (disabled)
+	RateLimit             float64            // max requests per second, waited for before sending, default 0 (unlimited)
+	Burst                 int                // max burst of requests allowed over RateLimit, default 1
+	MaxConcurrentRequests int                // max in-flight requests, waited for before sending, default 0 (unlimited)
+	Log                   *HttpLogCfg        // request and response logging through klog, default nil (disabled)
+	Hedge                 *HedgeCfg          // hedged requests for idempotent calls, default nil (disabled)
+	LoadBalancer          *LoadBalancerCfg   // load balancing over BaseUrls, default nil (round-robin)
+
+	// WrapTransport wraps the transport of the client outside of all other middlewares, e.g. for instrumentation with
+	// kotel.WrapHttpTransport. The wrapped transport can get the HttpRequestInfo of requests from their contexts.
+	WrapTransport func(next http.RoundTripper) http.RoundTripper `json:"-"`
+}
+
+// HttpRequestInfo is the info of a resty request passed to the WrapTransport of its client via the request context.
+type HttpRequestInfo struct {
+	URLTemplate string // request url before path params substitution, without scheme, host and query, e.g. "/users/{id}"
+	Attempt     int    // attempt of the request, starting from 1
+}
+
+type httpRequestInfoKey struct{}
+
+// HttpRequestInfoFromCtx returns the HttpRequestInfo of a resty request from the context of its http request.
+func HttpRequestInfoFromCtx(ctx context.Context) (HttpRequestInfo, bool) {
+	info, ok := ctx.Value(httpRequestInfoKey{}).(HttpRequestInfo)
+	return info, ok
 }
 
 // NewRestyClient creates a new resty client with the given configs
@@ -37,11 +79,14 @@ func (h *HttpCfg) NewRestyClient() (client *resty.Client) {
 		return resty.New()
 	}
 
-	hc := h.HttpClient
-	if hc == nil {
-		hc = &http.Client{Timeout: h.Timeout}
-	} else if hc.Timeout == 0 {
-		hc.Timeout = h.Timeout
+	hc := &http.Client{Timeout: h.Timeout}
+	if h.HttpClient != nil {
+		// copy the client so that wrapping its transport does not change the caller's one
+		c := *h.HttpClient
+		hc = &c
+		if hc.Timeout == 0 {
+			hc.Timeout = h.Timeout
+		}
 	}
 	client = resty.NewWithClient(hc)
 	if transport, err := client.Transport(); err == nil && transport != nil {
@@ -55,24 +100,27 @@ func (h *HttpCfg) NewRestyClient() (client *resty.Client) {
 		if h.MaxConnsPerHost != 0 {
 			transport.MaxConnsPerHost = h.MaxConnsPerHost
 		}
-		client.SetTransport(transport)
-		if h.UseH2c {
-			if h2cTransport, err := http2.ConfigureTransports(transport); err == nil {
-				h2cTransport.AllowHTTP = true
-			}
-			client.SetTransport(&http2.Transport{
-				AllowHTTP: true,
-				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
-					var d net.Dialer
-					return d.DialContext(ctx, network, addr)
-				},
-			})
+		var rt http.RoundTripper
+		if err = h.configureTransport(transport); err == nil {
+			rt, err = h.protocolTransport(transport)
+		}
+		if err != nil {
+			klog.Errorf(context.Background(), "HttpCfg.NewRestyClient|invalid configs: %v", err)
+			rt = errTransport{err: err}
 		}
+		client.SetTransport(rt)
 	}
 
+	lb, err := h.newLoadBalancer()
+	if err != nil {
+		klog.Errorf(context.Background(), "HttpCfg.NewRestyClient|invalid configs: %v", err)
+		client.SetTransport(errTransport{err: err})
+	}
+	client.SetTransport(h.wrapTransport(hc.Transport, lb))
+
 	client.SetBaseURL(h.BaseUrl).
 		SetRetryCount(h.RetryCount).
-		AddRetryCondition(retryableHttpError).
+		AddRetryCondition(h.retryCondition(client)).
 		SetDebug(h.Debug)
 	for key, values := range h.Headers {
 		for _, value := range values {
@@ -85,13 +133,92 @@ func (h *HttpCfg) NewRestyClient() (client *resty.Client) {
 	if maxWaitTime := h.RetryMaxWaitTime; maxWaitTime != 0 {
 		client.SetRetryMaxWaitTime(maxWaitTime)
 	}
+	if h.WrapTransport != nil {
+		client.OnBeforeRequest(initHttpRequestInfo)
+	}
+	if h.Log != nil {
+		logger := newHttpLogger(*h.Log)
+		client.OnAfterResponse(logger.logResponse).
+			OnError(logger.logError)
+	}
+	if h.GenIdempotencyKey {
+		client.OnBeforeRequest(h.genIdempotencyKey)
+	}
+	if h.RetryCount > 0 {
+		client.OnBeforeRequest(initRetryState).
+			SetRetryAfter(h.retryAfter)
+	}
+	if lb != nil {
+		client.OnBeforeRequest(lb.selectEndpoint)
+	}
 	client.JSONMarshal = JSONMarshal
 	client.JSONUnmarshal = JSONUnmarshal
 	return client
 }
 
+// newLoadBalancer creates the load balancer over BaseUrls if any.
+func (h *HttpCfg) newLoadBalancer() (*loadBalancer, error) {
+	if len(h.BaseUrls) == 0 {
+		return nil, nil
+	}
+	var cfg LoadBalancerCfg
+	if h.LoadBalancer != nil {
+		cfg = *h.LoadBalancer
+	}
+	return newLoadBalancer(h.BaseUrls, cfg, RealClock)
+}
+
+// wrapTransport wraps the base transport of a resty client with the optional middlewares configured.
+func (h *HttpCfg) wrapTransport(transport http.RoundTripper, lb *loadBalancer) http.RoundTripper {
+	if h.RateLimit > 0 || h.MaxConcurrentRequests > 0 {
+		transport = newRateLimitTransport(transport, h.RateLimit, h.Burst, h.MaxConcurrentRequests)
+	}
+	if h.Hedge != nil {
+		transport = newHedgeTransport(transport, *h.Hedge, h.idempotent, RealClock)
+	}
+	if h.CircuitBreaker != nil {
+		transport = newCircuitBreakerTransport(transport, *h.CircuitBreaker, RealClock)
+	}
+	if lb != nil {
+		transport = &loadBalancerTransport{next: transport, lb: lb}
+	}
+	if h.WrapTransport != nil {
+		transport = h.WrapTransport(transport)
+	}
+	return transport
+}
+
+// initHttpRequestInfo is a resty request middleware passing the HttpRequestInfo of a request to WrapTransport. It runs
+// before resty substitutes path params into the url, and before load balancing prefixes it with an endpoint url.
+func initHttpRequestInfo(_ *resty.Client, r *resty.Request) error {
+	r.SetContext(context.WithValue(r.Context(), httpRequestInfoKey{}, HttpRequestInfo{
+		URLTemplate: urlTemplate(r.URL),
+		Attempt:     r.Attempt,
+	}))
+	return nil
+}
+
+// urlTemplate strips the scheme, host and query of a url.
+func urlTemplate(rawURL string) string {
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rawURL = rawURL[i+3:]
+		if i = strings.IndexByte(rawURL, '/'); i >= 0 {
+			rawURL = rawURL[i:]
+		} else {
+			rawURL = "/"
+		}
+	}
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	if !strings.HasPrefix(rawURL, "/") {
+		rawURL = "/" + rawURL
+	}
+	return rawURL
+}
+
 func retryableHttpError(r *resty.Response, err error) bool {
-	if r == nil {
+	if r == nil || errors.Is(err, ErrCircuitOpen) {
 		return false
 	}
 	switch r.StatusCode() {
@@ -0,0 +1,264 @@
+package kutils
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // requests pass through, failures are counted
+	CircuitOpen                         // requests are rejected with a *CircuitBreakerError
+	CircuitHalfOpen                     // a few probe requests pass through to decide whether to close or reopen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerError is returned for requests short-circuited by a circuit breaker. It wraps ErrCircuitOpen.
+type CircuitBreakerError struct {
+	Host  string
+	State CircuitState
+}
+
+func (e *CircuitBreakerError) Error() string {
+	return "circuit breaker " + e.State.String() + " for host " + e.Host
+}
+
+func (e *CircuitBreakerError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreakerCfg configures per-host circuit breakers of a resty client. A breaker trips open on either of the
+// enabled trip conditions, rejects requests while open, then half-opens after OpenTimeout to let HalfOpenRequests
+// probes through: it closes if they all succeed or reopens on the first failure.
+type CircuitBreakerCfg struct {
+	ConsecutiveFailures int           // consecutive failures tripping the breaker, default 0 (disabled)
+	FailureRatio        float64       // failure ratio within Interval tripping the breaker, default 0 (disabled)
+	MinRequests         int           // min requests within Interval for FailureRatio to apply, default 10
+	Interval            time.Duration // window for counting requests while closed, default 10s
+	OpenTimeout         time.Duration // time to stay open before half-opening, default 30s
+	HalfOpenRequests    int           // probe requests allowed while half-open, default 1
+	// IsFailure decides whether a request failed, default on errors, 5xx and 429 statuses. Cancelled or rate limited
+	// requests are ignored whatever it returns.
+	IsFailure func(resp *http.Response, err error) bool `json:"-"`
+	// OnStateChange is called on state changes of the breaker of a host
+	OnStateChange func(host string, from, to CircuitState) `json:"-"`
+}
+
+func (c CircuitBreakerCfg) withDefaults() CircuitBreakerCfg {
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.Interval <= 0 {
+		c.Interval = 10 * time.Second
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = isHttpFailure
+	}
+	return c
+}
+
+func isHttpFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !ignoredHttpError(err)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// ignoredHttpError reports whether a request ended on the client side before getting an answer, i.e. was cancelled or
+// gave up waiting for the rate limiter, so that it tells nothing about the health of the upstream.
+func ignoredHttpError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimited)
+}
+
+// circuitBreakerTransport guards requests to each host with a circuitBreaker.
+type circuitBreakerTransport struct {
+	next     http.RoundTripper
+	cfg      CircuitBreakerCfg
+	clock    Clock
+	breakers sync.Map // host -> *circuitBreaker
+}
+
+func newCircuitBreakerTransport(next http.RoundTripper, cfg CircuitBreakerCfg, clock Clock) *circuitBreakerTransport {
+	return &circuitBreakerTransport{
+		next:  next,
+		cfg:   cfg.withDefaults(),
+		clock: clock,
+	}
+}
+
+func (t *circuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	breaker := t.breaker(req.URL.Host)
+	done, err := breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case ignoredHttpError(err):
+		done(requestIgnored)
+	case t.cfg.IsFailure(resp, err):
+		done(requestFailed)
+	default:
+		done(requestSucceeded)
+	}
+	return resp, err
+}
+
+func (t *circuitBreakerTransport) breaker(host string) *circuitBreaker {
+	if breaker, ok := t.breakers.Load(host); ok {
+		return breaker.(*circuitBreaker)
+	}
+	breaker, _ := t.breakers.LoadOrStore(host, &circuitBreaker{
+		host:  host,
+		cfg:   &t.cfg,
+		clock: t.clock,
+	})
+	return breaker.(*circuitBreaker)
+}
+
+// requestResult is the result of a request allowed by a circuitBreaker.
+type requestResult int
+
+const (
+	requestSucceeded requestResult = iota
+	requestFailed
+	requestIgnored // e.g. cancelled: neither a success nor a failure, only frees the slot of the request
+)
+
+// circuitBreaker is the circuit breaker of a host. Each state change starts a new generation, so that results of
+// requests allowed in previous generations are ignored.
+type circuitBreaker struct {
+	host  string
+	cfg   *CircuitBreakerCfg
+	clock Clock
+
+	mu                  sync.Mutex
+	state               CircuitState
+	generation          uint64
+	expiry              time.Time // end of the counting window if closed, or of the open timeout if open
+	requests            int
+	failures            int
+	consecutiveFailures int
+	successes           int // successful probes while half-open
+}
+
+// allow checks whether a request can be made, and returns a function to report its result if so.
+func (b *circuitBreaker) allow() (func(result requestResult), error) {
+	b.mu.Lock()
+	from := b.state
+	b.refresh(b.clock.Now())
+	state, generation := b.state, b.generation
+	if state == CircuitOpen || state == CircuitHalfOpen && b.requests >= b.cfg.HalfOpenRequests {
+		b.mu.Unlock()
+		b.notify(from, state)
+		return nil, &CircuitBreakerError{Host: b.host, State: state}
+	}
+	b.requests++
+	b.mu.Unlock()
+	b.notify(from, state)
+	return func(result requestResult) {
+		b.done(generation, result)
+	}, nil
+}
+
+func (b *circuitBreaker) done(generation uint64, result requestResult) {
+	b.mu.Lock()
+	from, now := b.state, b.clock.Now()
+	b.refresh(now)
+	switch {
+	case generation != b.generation:
+	case result == requestIgnored:
+		b.requests = max(b.requests-1, 0)
+	case result == requestFailed && b.state == CircuitHalfOpen:
+		b.setState(CircuitOpen, now)
+	case result == requestFailed:
+		b.failures++
+		b.consecutiveFailures++
+		if b.shouldTrip() {
+			b.setState(CircuitOpen, now)
+		}
+	case b.state == CircuitHalfOpen:
+		if b.successes++; b.successes >= b.cfg.HalfOpenRequests {
+			b.setState(CircuitClosed, now)
+		}
+	default:
+		b.consecutiveFailures = 0
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *circuitBreaker) shouldTrip() bool {
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.cfg.ConsecutiveFailures {
+		return true
+	}
+	return b.cfg.FailureRatio > 0 && b.requests >= b.cfg.MinRequests &&
+		float64(b.failures) >= b.cfg.FailureRatio*float64(b.requests)
+}
+
+// refresh half-opens an open breaker after its open timeout, or starts a new window for counting the failure ratio of a
+// closed breaker. It must be called with the lock held.
+func (b *circuitBreaker) refresh(now time.Time) {
+	switch b.state {
+	case CircuitClosed:
+		if b.expiry.IsZero() {
+			b.expiry = now.Add(b.cfg.Interval)
+		} else if !now.Before(b.expiry) {
+			b.expiry = now.Add(b.cfg.Interval)
+			b.requests, b.failures = 0, 0
+		}
+	case CircuitOpen:
+		if !now.Before(b.expiry) {
+			b.setState(CircuitHalfOpen, now)
+		}
+	default:
+	}
+}
+
+// setState moves to a new state in a new generation. It must be called with the lock held.
+func (b *circuitBreaker) setState(state CircuitState, now time.Time) {
+	b.state = state
+	b.generation++
+	b.requests, b.failures, b.consecutiveFailures, b.successes = 0, 0, 0, 0
+	switch state {
+	case CircuitClosed:
+		b.expiry = now.Add(b.cfg.Interval)
+	case CircuitOpen:
+		b.expiry = now.Add(b.cfg.OpenTimeout)
+	default:
+		b.expiry = time.Time{}
+	}
+}
+
+func (b *circuitBreaker) notify(from, to CircuitState) {
+	if from != to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.host, from, to)
+	}
+}
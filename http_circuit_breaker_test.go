@@ -0,0 +1,155 @@
+package kutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	type stateChange struct{ from, to CircuitState }
+
+	t.Run("consecutive failures", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var changes []stateChange
+		b := &circuitBreaker{host: "host", clock: clock, cfg: &CircuitBreakerCfg{}}
+		*b.cfg = CircuitBreakerCfg{
+			ConsecutiveFailures: 2,
+			OpenTimeout:         time.Second,
+			HalfOpenRequests:    2,
+			OnStateChange: func(host string, from, to CircuitState) {
+				assert.Equal(t, "host", host)
+				changes = append(changes, stateChange{from, to})
+			},
+		}
+		*b.cfg = b.cfg.withDefaults()
+
+		request := func(failed bool) error {
+			done, err := b.allow()
+			if err == nil {
+				done(resultOf(failed))
+			}
+			return err
+		}
+		assert.NoError(t, request(true))
+		assert.NoError(t, request(false))
+		assert.NoError(t, request(true))
+		assert.NoError(t, request(true))
+		err := request(false)
+		var cbErr *CircuitBreakerError
+		require.ErrorAs(t, err, &cbErr)
+		assert.Equal(t, CircuitBreakerError{Host: "host", State: CircuitOpen}, *cbErr)
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+
+		clock.Advance(time.Second)
+		done0, err := b.allow()
+		require.NoError(t, err)
+		done1, err := b.allow()
+		require.NoError(t, err)
+		_, err = b.allow()
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		done0(requestSucceeded)
+		done1(requestFailed)
+		assert.ErrorIs(t, request(false), ErrCircuitOpen)
+
+		clock.Advance(time.Second)
+		assert.NoError(t, request(false))
+		assert.NoError(t, request(false))
+		assert.NoError(t, request(true))
+		assert.Equal(t, []stateChange{{CircuitClosed, CircuitOpen}, {CircuitOpen, CircuitHalfOpen},
+			{CircuitHalfOpen, CircuitOpen}, {CircuitOpen, CircuitHalfOpen}, {CircuitHalfOpen, CircuitClosed}}, changes)
+	})
+
+	t.Run("failure ratio", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		cfg := CircuitBreakerCfg{FailureRatio: 0.5, MinRequests: 4, Interval: time.Second}.withDefaults()
+		b := &circuitBreaker{host: "host", clock: clock, cfg: &cfg}
+		request := func(failed bool) error {
+			done, err := b.allow()
+			if err == nil {
+				done(resultOf(failed))
+			}
+			return err
+		}
+		for _, failed := range []bool{true, false, true} {
+			assert.NoError(t, request(failed))
+		}
+		clock.Advance(time.Second) // new window
+		for _, failed := range []bool{false, true, false, true} {
+			assert.NoError(t, request(failed))
+		}
+		assert.ErrorIs(t, request(false), ErrCircuitOpen)
+	})
+
+	t.Run("cancelled requests", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var changes []stateChange
+		cfg := CircuitBreakerCfg{
+			ConsecutiveFailures: 2,
+			OpenTimeout:         time.Second,
+			OnStateChange: func(_ string, from, to CircuitState) {
+				changes = append(changes, stateChange{from, to})
+			},
+		}
+		var cancelled atomic.Bool
+		transport := newCircuitBreakerTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if cancelled.Load() {
+				return nil, context.Canceled
+			}
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Request: req}, nil
+		}), cfg, clock)
+		request := func(cancel bool) error {
+			cancelled.Store(cancel)
+			req := httptest.NewRequest(http.MethodGet, "http://host/", nil)
+			_, err := transport.RoundTrip(req)
+			return err
+		}
+
+		assert.NoError(t, request(false))
+		assert.ErrorIs(t, request(true), context.Canceled) // does not reset consecutive failures
+		assert.NoError(t, request(false))
+		assert.ErrorIs(t, request(false), ErrCircuitOpen)
+
+		clock.Advance(time.Second)
+		assert.ErrorIs(t, request(true), context.Canceled) // frees the probe slot without closing the breaker
+		assert.NoError(t, request(false))
+		assert.ErrorIs(t, request(false), ErrCircuitOpen)
+		assert.Equal(t, []stateChange{{CircuitClosed, CircuitOpen}, {CircuitOpen, CircuitHalfOpen},
+			{CircuitHalfOpen, CircuitOpen}}, changes)
+	})
+}
+
+func resultOf(failed bool) requestResult {
+	if failed {
+		return requestFailed
+	}
+	return requestSucceeded
+}
+
+func TestHttpCfg_circuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := (&HttpCfg{
+		BaseUrl:        srv.URL,
+		RetryCount:     5,
+		RetryWaitTime:  time.Millisecond,
+		CircuitBreaker: &CircuitBreakerCfg{ConsecutiveFailures: 2},
+	}).NewRestyClient()
+	_, err := client.R().Get("/")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.EqualValues(t, 2, calls.Load())
+	assert.False(t, retryableHttpError(&resty.Response{}, errors.WithStack(&CircuitBreakerError{})))
+}
@@ -0,0 +1,222 @@
+package kutils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	hedgeLatencyWindow     = 1000 // number of latest latencies tracked for the p95 delay
+	hedgeLatencyMinSamples = 20   // min number of latencies tracked before hedging with the p95 delay
+)
+
+// HedgeCfg configures hedged requests: an idempotent request still unanswered after Delay is sent again, up to
+// MaxHedges times, then the first successful response is returned and the other requests are cancelled. Hedges are
+// limited by a per-client budget allowing Budget hedges per request on average, in bursts of up to BudgetBurst.
+type HedgeCfg struct {
+	Delay       time.Duration // delay before each hedge, default 0 (the p95 latency of the latest 1000 requests)
+	MaxHedges   int           // max hedges per request, default 1
+	Budget      float64       // max ratio of hedges to requests, default 0.1
+	BudgetBurst int           // max hedges in a burst, default 10
+}
+
+func (c HedgeCfg) withDefaults() HedgeCfg {
+	if c.MaxHedges <= 0 {
+		c.MaxHedges = 1
+	}
+	if c.Budget <= 0 {
+		c.Budget = 0.1
+	}
+	if c.BudgetBurst <= 0 {
+		c.BudgetBurst = 10
+	}
+	return c
+}
+
+// hedgeTransport hedges idempotent requests whose body can be replayed.
+type hedgeTransport struct {
+	next       http.RoundTripper
+	cfg        HedgeCfg
+	idempotent func(method string, header http.Header) bool
+	clock      Clock
+	latencies  latencyTracker
+
+	budgetMu sync.Mutex
+	budget   float64
+}
+
+func newHedgeTransport(next http.RoundTripper, cfg HedgeCfg, idempotent func(method string, header http.Header) bool,
+	clock Clock) *hedgeTransport {
+	cfg = cfg.withDefaults()
+	return &hedgeTransport{
+		next:       next,
+		cfg:        cfg,
+		idempotent: idempotent,
+		clock:      clock,
+		budget:     float64(cfg.BudgetBurst),
+	}
+}
+
+type hedgeResult struct {
+	resp *http.Response
+	err  error
+	idx  int
+}
+
+func (t *hedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if !t.idempotent(req.Method, req.Header) || hasBody && req.GetBody == nil {
+		return t.next.RoundTrip(req)
+	}
+	t.deposit()
+	start := t.clock.Now()
+	results := make(chan hedgeResult, t.cfg.MaxHedges+1)
+	cancels := make([]context.CancelFunc, 0, t.cfg.MaxHedges+1)
+	send := func() error {
+		ctx, cancel := context.WithCancel(req.Context())
+		attemptReq, idx := req.WithContext(ctx), len(cancels)
+		if idx > 0 && hasBody {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			attemptReq.Body = body
+		}
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.next.RoundTrip(attemptReq)
+			results <- hedgeResult{resp: resp, err: err, idx: idx}
+		}()
+		return nil
+	}
+	_ = send()
+	inflight := 1
+
+	var timer Timer
+	var timerC <-chan time.Time
+	delay := t.delay()
+	if delay > 0 {
+		timer = t.clock.NewTimer(delay)
+		defer timer.Stop()
+		timerC = timer.C()
+	}
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			succeeded := res.err == nil && !isHttpFailure(res.resp, nil)
+			if !succeeded && inflight > 0 {
+				cancels[res.idx]()
+				discardResponse(res.resp)
+				continue
+			}
+			for idx, cancel := range cancels {
+				if idx != res.idx {
+					cancel()
+				}
+			}
+			if inflight > 0 {
+				go func() {
+					for range inflight {
+						discardResponse((<-results).resp)
+					}
+				}()
+			}
+			if succeeded {
+				t.latencies.add(t.clock.Now().Sub(start))
+			}
+			if res.err != nil || res.resp.Body == nil {
+				cancels[res.idx]()
+				return res.resp, res.err
+			}
+			res.resp.Body = &releaseOnCloseBody{ReadCloser: res.resp.Body, release: cancels[res.idx]}
+			return res.resp, nil
+		case <-timerC:
+			timerC = nil
+			if t.withdraw() && send() == nil {
+				inflight++
+				if len(cancels) <= t.cfg.MaxHedges {
+					timer.Reset(delay)
+					timerC = timer.C()
+				}
+			}
+		}
+	}
+}
+
+// delay returns the delay before hedging, or 0 to not hedge.
+func (t *hedgeTransport) delay() time.Duration {
+	if t.cfg.Delay > 0 {
+		return t.cfg.Delay
+	}
+	return t.latencies.p95()
+}
+
+// deposit adds the budget of a request.
+func (t *hedgeTransport) deposit() {
+	t.budgetMu.Lock()
+	defer t.budgetMu.Unlock()
+	t.budget = min(t.budget+t.cfg.Budget, float64(t.cfg.BudgetBurst))
+}
+
+// withdraw takes the budget of a hedge if available.
+func (t *hedgeTransport) withdraw() bool {
+	t.budgetMu.Lock()
+	defer t.budgetMu.Unlock()
+	if t.budget < 1 {
+		return false
+	}
+	t.budget--
+	return true
+}
+
+// discardResponse drains and closes the body of an unused response so that its connection can be reused.
+func discardResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+	_ = resp.Body.Close()
+}
+
+// latencyTracker tracks the latest hedgeLatencyWindow latencies to estimate their p95.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration // ring buffer of latencies
+	next    int             // index of the next sample to overwrite once samples is full
+	p95Val  time.Duration
+	stale   int // number of samples added since p95Val was computed
+}
+
+func (l *latencyTracker) add(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < hedgeLatencyWindow {
+		l.samples = append(l.samples, latency)
+	} else {
+		l.samples[l.next] = latency
+		l.next = (l.next + 1) % hedgeLatencyWindow
+	}
+	l.stale++
+}
+
+// p95 returns the p95 of tracked latencies, recomputed every 10 samples, or 0 if there are not enough samples yet.
+func (l *latencyTracker) p95() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < hedgeLatencyMinSamples {
+		return 0
+	}
+	if l.p95Val == 0 || l.stale >= 10 {
+		sorted := slices.Clone(l.samples)
+		slices.Sort(sorted)
+		l.p95Val = sorted[len(sorted)*95/100]
+		l.stale = 0
+	}
+	return l.p95Val
+}
@@ -0,0 +1,120 @@
+package kutils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHedgeServer returns a server responding to the first request only once its context is done, and to the others
+// right away with their number and body.
+func newHedgeServer(t *testing.T) (*httptest.Server, *atomic.Int32, chan struct{}) {
+	var calls atomic.Int32
+	cancelled := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if call == 1 && r.Header.Get("X-Fast") == "" {
+			<-r.Context().Done()
+			cancelled <- struct{}{}
+			return
+		}
+		_, _ = w.Write([]byte(string(rune('0'+call)) + string(body)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls, cancelled
+}
+
+func TestHedgeTransport(t *testing.T) {
+	srv, calls, cancelled := newHedgeServer(t)
+	clock := NewFakeClock(time.Now())
+	transport := newHedgeTransport(&http.Transport{}, HedgeCfg{Delay: 50 * time.Millisecond, BudgetBurst: 1},
+		(&HttpCfg{}).idempotent, clock)
+	roundTrip := func(req *http.Request) chan *http.Response {
+		respCh := make(chan *http.Response, 1)
+		go func() {
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				resp = &http.Response{Body: io.NopCloser(strings.NewReader(err.Error()))}
+			}
+			respCh <- resp
+		}()
+		return respCh
+	}
+	readBody := func(resp *http.Response) string {
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return string(body)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("body"))
+	require.NoError(t, err)
+	respCh := roundTrip(req)
+	clock.BlockUntil(1)
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(50 * time.Millisecond)
+	assert.Equal(t, "2body", readBody(<-respCh))
+	<-cancelled
+	assert.EqualValues(t, 2, calls.Load())
+
+	// out of budget
+	calls.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	respCh = roundTrip(req)
+	clock.BlockUntil(1)
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(50 * time.Millisecond)
+	cancel()
+	assert.Contains(t, readBody(<-respCh), context.Canceled.Error())
+	<-cancelled
+	assert.EqualValues(t, 1, calls.Load())
+
+	// not idempotent
+	calls.Store(0)
+	transport.budget = 1
+	req, err = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("body"))
+	require.NoError(t, err)
+	req.Header.Set("X-Fast", "1")
+	assert.Equal(t, "1body", readBody(<-roundTrip(req)))
+	assert.EqualValues(t, 1, calls.Load())
+	assert.Equal(t, 1.0, transport.budget)
+}
+
+func TestHttpCfg_Hedge(t *testing.T) {
+	srv, calls, cancelled := newHedgeServer(t)
+	client := (&HttpCfg{BaseUrl: srv.URL, Hedge: &HedgeCfg{Delay: 10 * time.Millisecond}}).NewRestyClient()
+
+	resp, err := client.R().SetHeader(HeaderIdempotencyKey, "key").SetBody("body").Post("/")
+	require.NoError(t, err)
+	assert.Equal(t, "2body", resp.String())
+	<-cancelled
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestLatencyTracker(t *testing.T) {
+	var l latencyTracker
+	for i := range hedgeLatencyMinSamples - 1 {
+		l.add(time.Duration(i))
+	}
+	assert.Zero(t, l.p95())
+	for i := hedgeLatencyMinSamples - 1; i < 100; i++ {
+		l.add(time.Duration(i))
+	}
+	assert.Equal(t, time.Duration(95), l.p95())
+	for range hedgeLatencyWindow {
+		l.add(time.Second)
+	}
+	assert.Len(t, l.samples, hedgeLatencyWindow)
+	assert.Equal(t, time.Second, l.p95())
+}
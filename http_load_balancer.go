@@ -0,0 +1,234 @@
+package kutils
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+// LoadBalancerStrategy is the strategy of picking an endpoint for each request.
+type LoadBalancerStrategy string
+
+const (
+	LoadBalancerRoundRobin       LoadBalancerStrategy = ""                  // endpoints in turn
+	LoadBalancerLeastOutstanding LoadBalancerStrategy = "least_outstanding" // endpoint with fewest in-flight requests
+	LoadBalancerWeighted         LoadBalancerStrategy = "weighted"          // smooth weighted round-robin per Weights
+)
+
+// LoadBalancerCfg configures load balancing of requests over the BaseUrls of a resty client. Requests with relative urls
+// are sent to an endpoint picked per Strategy among healthy ones, and retries go to endpoints not tried yet by the
+// request if any. An endpoint failing MaxFailures times in a row is ejected for EjectTime, unless all are ejected.
+type LoadBalancerCfg struct {
+	Strategy    LoadBalancerStrategy // "" (round-robin), "least_outstanding" or "weighted", default round-robin
+	Weights     []int                // weights of BaseUrls for the weighted strategy, default 1 each
+	MaxFailures int                  // consecutive failures ejecting an endpoint, default 5
+	EjectTime   time.Duration        // time an endpoint stays ejected, default 30s
+	// IsFailure decides whether a request failed, default on errors, 5xx and 429 statuses. Cancelled or rate limited
+	// requests are ignored whatever it returns.
+	IsFailure func(resp *http.Response, err error) bool `json:"-"`
+}
+
+func (c LoadBalancerCfg) withDefaults() LoadBalancerCfg {
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = 5
+	}
+	if c.EjectTime <= 0 {
+		c.EjectTime = 30 * time.Second
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = isHttpFailure
+	}
+	return c
+}
+
+// lbEndpoint is an endpoint of a loadBalancer.
+type lbEndpoint struct {
+	url           string
+	host          string // for logging, as urls may contain api keys
+	weight        int
+	currentWeight int // of smooth weighted round-robin, guarded by loadBalancer.mu
+	outstanding   atomic.Int64
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	ejectedUntil        time.Time
+}
+
+// lbState tracks the endpoints tried by a request across attempts.
+type lbState struct {
+	tried []*lbEndpoint
+}
+
+type lbStateKey struct{}
+
+type lbEndpointKey struct{}
+
+// loadBalancer picks endpoints for requests in a resty request middleware, and tracks their outstanding requests and
+// health in a transport.
+type loadBalancer struct {
+	cfg       LoadBalancerCfg
+	clock     Clock
+	endpoints []*lbEndpoint
+	next      atomic.Uint64
+
+	mu sync.Mutex // guards weighted picks
+}
+
+func newLoadBalancer(baseUrls []string, cfg LoadBalancerCfg, clock Clock) (*loadBalancer, error) {
+	switch cfg.Strategy {
+	case LoadBalancerRoundRobin, LoadBalancerLeastOutstanding, LoadBalancerWeighted:
+	default:
+		return nil, errors.Errorf("newLoadBalancer|invalid Strategy %q", cfg.Strategy)
+	}
+	lb := &loadBalancer{
+		cfg:       cfg.withDefaults(),
+		clock:     clock,
+		endpoints: make([]*lbEndpoint, len(baseUrls)),
+	}
+	for i, baseUrl := range baseUrls {
+		weight := 1
+		if i < len(cfg.Weights) {
+			if weight = cfg.Weights[i]; weight <= 0 {
+				return nil, errors.Errorf("newLoadBalancer|invalid weight %d of endpoint %d", weight, i)
+			}
+		}
+		u, err := url.Parse(baseUrl)
+		if err != nil || u.Host == "" {
+			return nil, errors.Errorf("newLoadBalancer|invalid base url %s", baseUrl)
+		}
+		lb.endpoints[i] = &lbEndpoint{url: strings.TrimRight(baseUrl, "/"), host: u.Host, weight: weight}
+	}
+	return lb, nil
+}
+
+// selectEndpoint is a resty request middleware prefixing relative request urls with the url of a picked endpoint.
+func (lb *loadBalancer) selectEndpoint(_ *resty.Client, r *resty.Request) error {
+	if strings.Contains(r.URL, "://") {
+		return nil
+	}
+	state, ok := r.Context().Value(lbStateKey{}).(*lbState)
+	if !ok || r.Attempt <= 1 {
+		state = &lbState{}
+	}
+	endpoint := lb.pick(state.tried)
+	state.tried = append(state.tried, endpoint)
+	ctx := context.WithValue(r.Context(), lbStateKey{}, state)
+	r.SetContext(context.WithValue(ctx, lbEndpointKey{}, endpoint))
+	if r.URL == "" {
+		r.URL = endpoint.url
+	} else {
+		r.URL = endpoint.url + "/" + strings.TrimLeft(r.URL, "/")
+	}
+	return nil
+}
+
+// pick picks an endpoint among healthy ones not tried yet, falling back to healthy ones, then to untried ones, then to
+// all endpoints.
+func (lb *loadBalancer) pick(tried []*lbEndpoint) *lbEndpoint {
+	now := lb.clock.Now()
+	var healthy, untried, candidates []*lbEndpoint
+	for _, endpoint := range lb.endpoints {
+		isHealthy, isUntried := endpoint.healthy(now), !slices.Contains(tried, endpoint)
+		if isHealthy && isUntried {
+			candidates = append(candidates, endpoint)
+		}
+		if isHealthy {
+			healthy = append(healthy, endpoint)
+		}
+		if isUntried {
+			untried = append(untried, endpoint)
+		}
+	}
+	switch {
+	case len(candidates) > 0:
+	case len(healthy) > 0:
+		candidates = healthy
+	case len(untried) > 0:
+		candidates = untried
+	default:
+		candidates = lb.endpoints
+	}
+
+	switch lb.cfg.Strategy {
+	case LoadBalancerLeastOutstanding:
+		start := int(lb.next.Add(1) % uint64(len(candidates)))
+		best := candidates[start]
+		for i := 1; i < len(candidates); i++ {
+			if endpoint := candidates[(start+i)%len(candidates)]; endpoint.outstanding.Load() < best.outstanding.Load() {
+				best = endpoint
+			}
+		}
+		return best
+	case LoadBalancerWeighted:
+		lb.mu.Lock()
+		defer lb.mu.Unlock()
+		var best *lbEndpoint
+		total := 0
+		for _, endpoint := range candidates {
+			endpoint.currentWeight += endpoint.weight
+			total += endpoint.weight
+			if best == nil || endpoint.currentWeight > best.currentWeight {
+				best = endpoint
+			}
+		}
+		best.currentWeight -= total
+		return best
+	default:
+		return candidates[(lb.next.Add(1)-1)%uint64(len(candidates))]
+	}
+}
+
+// report records the result of a request to an endpoint, ejecting it after MaxFailures consecutive failures.
+func (lb *loadBalancer) report(ctx context.Context, endpoint *lbEndpoint, failed bool) {
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+	if !failed {
+		endpoint.consecutiveFailures = 0
+		return
+	}
+	if endpoint.consecutiveFailures++; endpoint.consecutiveFailures >= lb.cfg.MaxFailures {
+		endpoint.consecutiveFailures = 0
+		endpoint.ejectedUntil = lb.clock.Now().Add(lb.cfg.EjectTime)
+		klog.Warnf(ctx, "loadBalancer.report|ejected %s for %s", endpoint.host, lb.cfg.EjectTime)
+	}
+}
+
+func (e *lbEndpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.ejectedUntil)
+}
+
+// loadBalancerTransport tracks outstanding requests and health of the endpoints picked for requests.
+type loadBalancerTransport struct {
+	next http.RoundTripper
+	lb   *loadBalancer
+}
+
+func (t *loadBalancerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint, _ := req.Context().Value(lbEndpointKey{}).(*lbEndpoint)
+	if endpoint == nil {
+		return t.next.RoundTrip(req)
+	}
+	endpoint.outstanding.Add(1)
+	resp, err := t.next.RoundTrip(req)
+	if !ignoredHttpError(err) {
+		t.lb.report(req.Context(), endpoint, t.lb.cfg.IsFailure(resp, err))
+	}
+	if err != nil || resp.Body == nil {
+		endpoint.outstanding.Add(-1)
+		return resp, err
+	}
+	resp.Body = &releaseOnCloseBody{ReadCloser: resp.Body, release: func() { endpoint.outstanding.Add(-1) }}
+	return resp, nil
+}
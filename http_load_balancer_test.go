@@ -0,0 +1,122 @@
+package kutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLoadBalancer(t *testing.T, cfg LoadBalancerCfg, clock Clock) *loadBalancer {
+	lb, err := newLoadBalancer([]string{"http://a", "http://b/", "http://c/path"}, cfg, clock)
+	require.NoError(t, err)
+	return lb
+}
+
+func pickUrls(lb *loadBalancer, n int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = lb.pick(nil).url
+	}
+	return urls
+}
+
+func TestLoadBalancer_pick(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	lb := newTestLoadBalancer(t, LoadBalancerCfg{}, clock)
+	assert.Equal(t, []string{"http://a", "http://b", "http://c/path", "http://a"}, pickUrls(lb, 4))
+
+	lb = newTestLoadBalancer(t, LoadBalancerCfg{Strategy: LoadBalancerWeighted, Weights: []int{5, 1, 1}}, clock)
+	assert.Equal(t, []string{"http://a", "http://a", "http://b", "http://a", "http://c/path", "http://a", "http://a"},
+		pickUrls(lb, 7))
+
+	lb = newTestLoadBalancer(t, LoadBalancerCfg{Strategy: LoadBalancerLeastOutstanding}, clock)
+	lb.endpoints[0].outstanding.Store(2)
+	lb.endpoints[2].outstanding.Store(1)
+	assert.Equal(t, []string{"http://b", "http://b"}, pickUrls(lb, 2))
+	lb.endpoints[1].outstanding.Store(3)
+	assert.Equal(t, "http://c/path", lb.pick(nil).url)
+
+	// failover to untried endpoints, then to tried ones
+	lb = newTestLoadBalancer(t, LoadBalancerCfg{}, clock)
+	assert.Equal(t, "http://c/path", lb.pick(lb.endpoints[:2]).url)
+	assert.Contains(t, lb.endpoints, lb.pick(lb.endpoints))
+
+	// ejection
+	lb = newTestLoadBalancer(t, LoadBalancerCfg{MaxFailures: 2, EjectTime: time.Minute}, clock)
+	lb.report(context.Background(), lb.endpoints[0], true)
+	lb.report(context.Background(), lb.endpoints[0], false)
+	lb.report(context.Background(), lb.endpoints[0], true)
+	assert.True(t, lb.endpoints[0].healthy(clock.Now()))
+	lb.report(context.Background(), lb.endpoints[0], true)
+	assert.False(t, lb.endpoints[0].healthy(clock.Now()))
+	assert.Equal(t, []string{"http://b", "http://c/path", "http://b"}, pickUrls(lb, 3))
+	assert.NotEqual(t, "http://a", lb.pick(lb.endpoints[1:]).url, "tried healthy endpoint over ejected one")
+	clock.Advance(time.Minute)
+	assert.True(t, lb.endpoints[0].healthy(clock.Now()))
+
+	for _, endpoint := range lb.endpoints {
+		endpoint.ejectedUntil = clock.Now().Add(time.Minute)
+	}
+	assert.Len(t, pickUrls(lb, 3), 3, "all endpoints ejected")
+}
+
+func Test_newLoadBalancer(t *testing.T) {
+	_, err := newLoadBalancer([]string{"http://a"}, LoadBalancerCfg{Strategy: "random"}, RealClock)
+	assert.ErrorContains(t, err, "invalid Strategy")
+	_, err = newLoadBalancer([]string{"http://a"}, LoadBalancerCfg{Weights: []int{0}}, RealClock)
+	assert.ErrorContains(t, err, "invalid weight")
+	_, err = newLoadBalancer([]string{"a"}, LoadBalancerCfg{}, RealClock)
+	assert.ErrorContains(t, err, "invalid base url")
+}
+
+func TestHttpCfg_LoadBalancer(t *testing.T) {
+	newServer := func(name string, status int) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(name + r.URL.Path))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	a, b := newServer("a", http.StatusOK), newServer("b", http.StatusServiceUnavailable)
+	client := (&HttpCfg{
+		BaseUrls:      []string{a.URL + "/v1", b.URL + "/v2"},
+		LoadBalancer:  &LoadBalancerCfg{MaxFailures: 2},
+		RetryCount:    1,
+		RetryWaitTime: time.Millisecond,
+	}).NewRestyClient()
+
+	var bodies []string
+	for range 4 {
+		resp, err := client.R().SetPathParam("id", "1").Get("/users/{id}")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		bodies = append(bodies, resp.String())
+	}
+	assert.Equal(t, []string{"a/v1/users/1", "a/v1/users/1", "a/v1/users/1", "a/v1/users/1"}, bodies)
+
+	resp, err := client.R().Get(b.URL + "/absolute")
+	require.NoError(t, err)
+	assert.Equal(t, "b/absolute", resp.String())
+
+	client = (&HttpCfg{
+		BaseUrls:       []string{b.URL + "/v2", a.URL + "/v1"},
+		RetryCount:     2,
+		RetryWaitTime:  time.Millisecond,
+		CircuitBreaker: &CircuitBreakerCfg{ConsecutiveFailures: 1},
+	}).NewRestyClient()
+	for _, method := range []string{http.MethodGet, http.MethodGet, http.MethodGet, http.MethodPost, http.MethodPost} {
+		// fails over from b, whose circuit breaker is open after the first request, whatever the method
+		resp, err := client.R().Execute(method, "/users")
+		require.NoError(t, err)
+		assert.Equal(t, "a/v1/users", resp.String())
+	}
+
+	_, err = (&HttpCfg{BaseUrls: []string{"invalid"}}).NewRestyClient().R().Get("/")
+	assert.ErrorContains(t, err, "invalid base url")
+}
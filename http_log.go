@@ -0,0 +1,147 @@
+package kutils
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+const redacted = "REDACTED"
+
+// defaultRedactHeaders are the headers always redacted from logs.
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// HttpLogCfg configures logging of requests and responses of a resty client with the logger of the request context.
+// Each response is logged with its method, url, status, latency and attempt as fields, at Level if successful or as a
+// warning for 5xx statuses. Failed requests are logged once as a warning with their number of attempts.
+type HttpLogCfg struct {
+	Level         string   // log level of successful requests, "debug" or "info", default "debug"
+	Headers       bool     // whether to log request and response headers
+	RedactHeaders []string // headers to redact, in addition to Authorization, Proxy-Authorization, Cookie and Set-Cookie
+	RedactQuery   []string // query params to redact from urls, e.g. api keys
+	MaxBodySize   int      // max logged bytes of request and response bodies, default 0 (bodies not logged)
+}
+
+// httpLogger is a resty middleware logging requests and responses per HttpLogCfg.
+type httpLogger struct {
+	cfg           HttpLogCfg
+	redactHeaders map[string]struct{}
+	redactQuery   map[string]struct{}
+}
+
+func newHttpLogger(cfg HttpLogCfg) *httpLogger {
+	l := &httpLogger{
+		cfg:           cfg,
+		redactHeaders: make(map[string]struct{}),
+		redactQuery:   make(map[string]struct{}, len(cfg.RedactQuery)),
+	}
+	for _, header := range slices.Concat(defaultRedactHeaders, cfg.RedactHeaders) {
+		l.redactHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	for _, param := range cfg.RedactQuery {
+		l.redactQuery[param] = struct{}{}
+	}
+	return l
+}
+
+func (l *httpLogger) logResponse(_ *resty.Client, resp *resty.Response) error {
+	r := resp.Request
+	fields := l.requestFields(r)
+	fields["status"] = resp.StatusCode()
+	fields["latency"] = resp.Time().String()
+	if l.cfg.Headers {
+		fields["resp_headers"] = l.headers(resp.Header())
+	}
+	if l.cfg.MaxBodySize > 0 {
+		fields["resp_body"] = truncateBody(resp.Body(), l.cfg.MaxBodySize)
+	}
+	logger := klog.LoggerFromCtx(r.Context()).WithFields(fields)
+	switch {
+	case resp.StatusCode() >= http.StatusInternalServerError:
+		logger.Warn("httpLogger.logResponse|failed")
+	case strings.EqualFold(l.cfg.Level, "info"):
+		logger.Info("httpLogger.logResponse|ok")
+	default:
+		logger.Debug("httpLogger.logResponse|ok")
+	}
+	return nil
+}
+
+func (l *httpLogger) logError(r *resty.Request, err error) {
+	fields := l.requestFields(r)
+	if !r.Time.IsZero() {
+		fields["latency"] = time.Since(r.Time).String()
+	}
+	fields["error"] = err.Error()
+	klog.LoggerFromCtx(r.Context()).WithFields(fields).Warn("httpLogger.logError|failed")
+}
+
+// requestFields returns the log fields of a request.
+func (l *httpLogger) requestFields(r *resty.Request) klog.Fields {
+	fields := klog.Fields{
+		"method":  r.Method,
+		"attempt": r.Attempt,
+	}
+	rawReq := r.RawRequest
+	if rawReq == nil {
+		fields["url"] = r.URL
+		return fields
+	}
+	fields["url"] = l.url(rawReq.URL)
+	if l.cfg.Headers {
+		fields["req_headers"] = l.headers(rawReq.Header)
+	}
+	if l.cfg.MaxBodySize > 0 && rawReq.GetBody != nil {
+		if body, err := rawReq.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			_ = body.Close()
+			fields["req_body"] = truncateBody(data, l.cfg.MaxBodySize)
+		}
+	}
+	return fields
+}
+
+// url returns u with configured query params and password redacted.
+func (l *httpLogger) url(u *url.URL) string {
+	if len(l.redactQuery) == 0 || u.RawQuery == "" {
+		return u.Redacted()
+	}
+	redactedURL := *u
+	query := redactedURL.Query()
+	for param := range query {
+		if _, ok := l.redactQuery[param]; ok {
+			query.Set(param, redacted)
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.Redacted()
+}
+
+// headers returns header with configured headers redacted.
+func (l *httpLogger) headers(header http.Header) http.Header {
+	res := make(http.Header, len(header))
+	for key, values := range header {
+		if _, ok := l.redactHeaders[http.CanonicalHeaderKey(key)]; ok {
+			res[key] = []string{redacted}
+		} else {
+			res[key] = values
+		}
+	}
+	return res
+}
+
+// truncateBody returns body as a string truncated to maxSize bytes.
+func truncateBody(body []byte, maxSize int) string {
+	if len(body) <= maxSize {
+		return string(body)
+	}
+	return string(body[:maxSize]) + "...(truncated " + strconv.Itoa(len(body)-maxSize) + " bytes)"
+}
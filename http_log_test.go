@@ -0,0 +1,129 @@
+package kutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields klog.Fields
+}
+
+// recordingLogger is a klog.Logger recording entries logged with fields.
+type recordingLogger struct {
+	mu      *sync.Mutex
+	entries *[]logEntry
+	fields  klog.Fields
+}
+
+func newRecordingLogger() recordingLogger {
+	return recordingLogger{mu: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+func (l recordingLogger) log(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.entries = append(*l.entries, logEntry{level: level, msg: msg, fields: l.fields})
+}
+
+func (l recordingLogger) Entries() []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return *l.entries
+}
+
+func (l recordingLogger) Debug(msg string)         { l.log("debug", msg) }
+func (l recordingLogger) Debugf(string, ...any)    {}
+func (l recordingLogger) Info(msg string)          { l.log("info", msg) }
+func (l recordingLogger) Infof(string, ...any)     {}
+func (l recordingLogger) Infoln(msg string)        { l.log("info", msg) }
+func (l recordingLogger) Warn(msg string)          { l.log("warn", msg) }
+func (l recordingLogger) Warnf(string, ...any)     {}
+func (l recordingLogger) Error(msg string)         { l.log("error", msg) }
+func (l recordingLogger) Errorf(string, ...any)    {}
+func (l recordingLogger) Fatal(msg string)         { l.log("fatal", msg) }
+func (l recordingLogger) Fatalf(string, ...any)    {}
+func (l recordingLogger) GetDelegate() any         { return nil }
+func (l recordingLogger) SetLogLevel(string) error { return nil }
+func (l recordingLogger) WithFields(fields klog.Fields) klog.Logger {
+	l.fields = fields
+	return l
+}
+
+func TestHttpCfg_log(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte("response body"))
+	}))
+	defer srv.Close()
+
+	logger := newRecordingLogger()
+	ctx := klog.CtxWithLogger(context.Background(), logger)
+	client := (&HttpCfg{
+		BaseUrl:    srv.URL,
+		RetryCount: 1,
+		Log: &HttpLogCfg{
+			Level:         "info",
+			Headers:       true,
+			RedactHeaders: []string{"x-api-key"},
+			RedactQuery:   []string{"key"},
+			MaxBodySize:   8,
+		},
+	}).NewRestyClient()
+
+	_, err := client.R().SetContext(ctx).
+		SetHeader("Authorization", "Bearer secret").
+		SetHeader("X-Api-Key", "secret").
+		SetHeader("X-Request-Id", "id").
+		SetQueryParams(map[string]string{"key": "secret", "q": "1"}).
+		SetBody("request body").
+		Post("/ok")
+	require.NoError(t, err)
+	_, err = client.R().SetContext(ctx).Get("/fail")
+	require.NoError(t, err)
+
+	entries := logger.Entries()
+	require.Len(t, entries, 3)
+	entry := entries[0]
+	assert.Equal(t, "info", entry.level)
+	assert.Equal(t, http.MethodPost, entry.fields["method"])
+	assert.Equal(t, srv.URL+"/ok?key=REDACTED&q=1", entry.fields["url"])
+	assert.Equal(t, http.StatusOK, entry.fields["status"])
+	assert.Equal(t, 1, entry.fields["attempt"])
+	assert.NotEmpty(t, entry.fields["latency"])
+	reqHeaders := entry.fields["req_headers"].(http.Header)
+	assert.Equal(t, "REDACTED", reqHeaders.Get("Authorization"))
+	assert.Equal(t, "REDACTED", reqHeaders.Get("X-Api-Key"))
+	assert.Equal(t, "id", reqHeaders.Get("X-Request-Id"))
+	assert.Equal(t, "REDACTED", entry.fields["resp_headers"].(http.Header).Get("Set-Cookie"))
+	assert.Equal(t, "request ...(truncated 4 bytes)", entry.fields["req_body"])
+	assert.Equal(t, "response...(truncated 5 bytes)", entry.fields["resp_body"])
+
+	for i, entry := range entries[1:] {
+		assert.Equal(t, "warn", entry.level)
+		assert.Equal(t, http.StatusBadGateway, entry.fields["status"])
+		assert.Equal(t, i+1, entry.fields["attempt"])
+	}
+
+	srv.Close()
+	_, err = client.R().SetContext(ctx).Get("/closed")
+	require.Error(t, err)
+	entries = logger.Entries()
+	require.Len(t, entries, 4)
+	assert.Equal(t, "warn", entries[3].level)
+	assert.Equal(t, 2, entries[3].fields["attempt"])
+	assert.Contains(t, entries[3].fields["error"], "connection refused")
+}
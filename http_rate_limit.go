@@ -0,0 +1,90 @@
+package kutils
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is returned for requests given up while waiting for the rate limiter or a concurrency slot. It wraps
+// both ErrRateLimited and the error ending the wait, e.g. context.DeadlineExceeded.
+type RateLimitError struct {
+	Err error
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limited: " + e.Err.Error()
+}
+
+func (e *RateLimitError) Unwrap() []error {
+	return []error{ErrRateLimited, e.Err}
+}
+
+// rateLimitTransport waits for the rate limiter and a concurrency slot before sending each request, giving up if the
+// request context is done first.
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter *rate.Limiter
+	sem     chan struct{}
+}
+
+func newRateLimitTransport(next http.RoundTripper, rateLimit float64, burst, maxConcurrent int) *rateLimitTransport {
+	t := &rateLimitTransport{next: next}
+	if rateLimit > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(rateLimit), max(burst, 1))
+	}
+	if maxConcurrent > 0 {
+		t.sem = make(chan struct{}, maxConcurrent)
+	}
+	return t
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.sem != nil {
+		select {
+		case t.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, &RateLimitError{Err: ctx.Err()}
+		}
+	}
+	if t.limiter != nil {
+		if err := t.limiter.Wait(ctx); err != nil {
+			t.release()
+			return nil, &RateLimitError{Err: err}
+		}
+	}
+	resp, err := t.next.RoundTrip(req)
+	if t.sem == nil {
+		return resp, err
+	} else if err != nil || resp.Body == nil {
+		t.release()
+		return resp, err
+	}
+	resp.Body = &releaseOnCloseBody{ReadCloser: resp.Body, release: t.release}
+	return resp, nil
+}
+
+func (t *rateLimitTransport) release() {
+	if t.sem != nil {
+		<-t.sem
+	}
+}
+
+// releaseOnCloseBody calls release once a response body is closed, i.e. once the request is over.
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,112 @@
+package kutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpCfg_rateLimit(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cnt := running.Add(1)
+		defer running.Add(-1)
+		for prev := maxRunning.Load(); cnt > prev && !maxRunning.CompareAndSwap(prev, cnt); {
+			prev = maxRunning.Load()
+		}
+		if r.URL.Path == "/block" {
+			<-release
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	t.Run("max concurrent requests", func(t *testing.T) {
+		client := (&HttpCfg{BaseUrl: srv.URL, MaxConcurrentRequests: 2}).NewRestyClient()
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.R().Get("/block")
+				assert.NoError(t, err)
+				assert.Equal(t, "ok", resp.String())
+			}()
+		}
+		for running.Load() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := client.R().SetContext(ctx).Get("/")
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(release)
+		wg.Wait()
+		resp, err := client.R().Get("/")
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", resp.String())
+		assert.EqualValues(t, 2, maxRunning.Load())
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		client := (&HttpCfg{BaseUrl: srv.URL, RateLimit: 50, Burst: 2}).NewRestyClient()
+		start := time.Now()
+		for range 4 {
+			_, err := client.R().Get("/")
+			assert.NoError(t, err)
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err := client.R().SetContext(ctx).Get("/")
+		assert.ErrorIs(t, err, ErrRateLimited)
+	})
+
+	t.Run("ignored by circuit breaker", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			started <- struct{}{}
+			<-release
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer srv.Close()
+		client := (&HttpCfg{
+			BaseUrl:               srv.URL,
+			MaxConcurrentRequests: 1,
+			CircuitBreaker:        &CircuitBreakerCfg{ConsecutiveFailures: 2},
+		}).NewRestyClient()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := client.R().Get("/")
+			assert.NoError(t, err)
+		}()
+		<-started
+		for range 2 {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			_, err := client.R().SetContext(ctx).Get("/")
+			cancel()
+			assert.ErrorIs(t, err, ErrRateLimited)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+		close(release)
+		<-done
+
+		go func() { <-started }()
+		resp, err := client.R().Get("/")
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", resp.String())
+	})
+}
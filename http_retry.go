@@ -0,0 +1,169 @@
+package kutils
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// HeaderIdempotencyKey is the header marking a request of a non-idempotent method as safe to retry.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// idempotentMethods are the methods retried by default, see RFC 9110 section 9.2.2.
+var idempotentMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete,
+}
+
+// RetryJitter is the jitter strategy of retry backoffs. Backoffs are at least RetryWaitTime and at most
+// RetryMaxWaitTime.
+type RetryJitter string
+
+const (
+	RetryJitterDefault      RetryJitter = ""             // random up to half the exponential backoff, as per resty
+	RetryJitterFull         RetryJitter = "full"         // random up to the exponential backoff
+	RetryJitterDecorrelated RetryJitter = "decorrelated" // random up to 3 times the previous backoff
+)
+
+// retryState tracks the retries of a request across attempts.
+type retryState struct {
+	start    time.Time
+	prevWait time.Duration
+}
+
+type retryStateKey struct{}
+
+// initRetryState is a resty request middleware starting to track retries on the first attempt of a request.
+func initRetryState(_ *resty.Client, r *resty.Request) error {
+	if _, ok := r.Context().Value(retryStateKey{}).(*retryState); !ok || r.Attempt <= 1 {
+		r.SetContext(context.WithValue(r.Context(), retryStateKey{}, &retryState{start: time.Now()}))
+	}
+	return nil
+}
+
+// retryCondition returns a resty.RetryConditionFunc retrying retryable errors of requests of RetryMethods, or of other
+// methods with an Idempotency-Key header, as they may have reached the server and retrying them could otherwise
+// duplicate their side effects. Like when RetryCount is exhausted, it stops retrying without error, returning the last
+// response, if the Retry-After header of the response is longer than RetryMaxWaitTime, or if waiting RetryWaitTime
+// would exceed RetryMaxTotalTime. Requests short-circuited by a circuit breaker are retried whatever their method if
+// there are other BaseUrls to fail over to, as they were not sent.
+func (h *HttpCfg) retryCondition(c *resty.Client) resty.RetryConditionFunc {
+	return func(r *resty.Response, err error) bool {
+		if r == nil || r.Request == nil {
+			return false
+		} else if errors.Is(err, ErrCircuitOpen) {
+			if len(h.BaseUrls) < 2 {
+				return false
+			}
+		} else if !h.retryableMethod(r.Request) || !retryableHttpError(r, err) {
+			return false
+		}
+		if wait, ok := parseRetryAfter(r.RawResponse, time.Now()); ok && wait > c.RetryMaxWaitTime {
+			return false
+		}
+		state, _ := r.Request.Context().Value(retryStateKey{}).(*retryState)
+		return h.RetryMaxTotalTime <= 0 || state == nil || time.Since(state.start)+c.RetryWaitTime < h.RetryMaxTotalTime
+	}
+}
+
+func (h *HttpCfg) retryableMethod(r *resty.Request) bool {
+	return h.idempotent(r.Method, r.Header)
+}
+
+// idempotent returns whether a request with the given method and header is safe to send multiple times, i.e. whether
+// its method is in RetryMethods or it has an Idempotency-Key header.
+func (h *HttpCfg) idempotent(method string, header http.Header) bool {
+	methods := h.RetryMethods
+	if methods == nil {
+		methods = idempotentMethods
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return header.Get(HeaderIdempotencyKey) != ""
+}
+
+// genIdempotencyKey is a resty request middleware adding a random Idempotency-Key header to requests of methods not
+// retried by default, unless they already have one. The key is kept across retries of the same request.
+func (h *HttpCfg) genIdempotencyKey(_ *resty.Client, r *resty.Request) error {
+	if h.retryableMethod(r) {
+		return nil
+	}
+	var key [16]byte
+	if _, err := crand.Read(key[:]); err != nil {
+		return errors.WithMessage(err, "HttpCfg.genIdempotencyKey|failed to generate key")
+	}
+	r.SetHeader(HeaderIdempotencyKey, hex.EncodeToString(key[:]))
+	return nil
+}
+
+// retryAfter is a resty.RetryAfterFunc waiting as requested by the Retry-After header of the response if any, or per
+// the configured jitter strategy otherwise, within RetryWaitTime and RetryMaxWaitTime. The wait is cut short to not
+// exceed RetryMaxTotalTime, which retryCondition stops retrying at.
+func (h *HttpCfg) retryAfter(c *resty.Client, r *resty.Response) (time.Duration, error) {
+	state, _ := r.Request.Context().Value(retryStateKey{}).(*retryState)
+	if state == nil {
+		state = &retryState{start: time.Now()}
+	}
+	wait, ok := parseRetryAfter(r.RawResponse, time.Now())
+	if !ok {
+		wait = retryBackoff(h.RetryJitter, c.RetryWaitTime, c.RetryMaxWaitTime, r.Request.Attempt, state.prevWait)
+	}
+	wait = min(max(wait, c.RetryWaitTime), c.RetryMaxWaitTime)
+	if h.RetryMaxTotalTime > 0 {
+		wait = max(min(wait, h.RetryMaxTotalTime-time.Since(state.start)), c.RetryWaitTime)
+	}
+	state.prevWait = wait
+	return wait, nil
+}
+
+// retryBackoff returns the backoff before retrying after the given attempt (starting from 1) per jitter strategy.
+func retryBackoff(jitter RetryJitter, minWait, maxWait time.Duration, attempt int, prevWait time.Duration) time.Duration {
+	if jitter == RetryJitterDecorrelated {
+		upper := min(max(3*prevWait, minWait), maxWait)
+		return minWait + randDuration(upper-minWait)
+	}
+	backoff := minWait
+	for i := 1; i < attempt && backoff < maxWait; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxWait)
+	if jitter == RetryJitterFull {
+		return randDuration(backoff)
+	}
+	return randDuration(backoff / 2)
+}
+
+func randDuration(upper time.Duration) time.Duration {
+	if upper <= 0 {
+		return 0
+	}
+	return rand.N(upper)
+}
+
+// parseRetryAfter parses the Retry-After header of a response, either in seconds or as an HTTP-date.
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
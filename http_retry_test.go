@@ -0,0 +1,196 @@
+package kutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Now()
+	resp := func(retryAfter string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": {retryAfter}}}
+	}
+	wait, ok := parseRetryAfter(resp("3"), now)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, wait)
+	wait, ok = parseRetryAfter(resp(now.Add(5*time.Second).UTC().Format(http.TimeFormat)), now)
+	assert.True(t, ok)
+	assert.InDelta(t, 5*time.Second, wait, float64(time.Second))
+	wait, ok = parseRetryAfter(resp(now.Add(-time.Minute).UTC().Format(http.TimeFormat)), now)
+	assert.True(t, ok)
+	assert.Zero(t, wait)
+	_, ok = parseRetryAfter(resp("soon"), now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter(&http.Response{}, now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter(nil, now)
+	assert.False(t, ok)
+}
+
+func Test_retryBackoff(t *testing.T) {
+	minWait, maxWait := 10*time.Millisecond, 100*time.Millisecond
+	for range 100 {
+		assert.LessOrEqual(t, retryBackoff(RetryJitterDefault, minWait, maxWait, 3, 0), 20*time.Millisecond)
+		assert.LessOrEqual(t, retryBackoff(RetryJitterFull, minWait, maxWait, 3, 0), 40*time.Millisecond)
+		assert.LessOrEqual(t, retryBackoff(RetryJitterFull, minWait, maxWait, 100, 0), maxWait)
+		wait := retryBackoff(RetryJitterDecorrelated, minWait, maxWait, 3, 20*time.Millisecond)
+		assert.GreaterOrEqual(t, wait, minWait)
+		assert.LessOrEqual(t, wait, 60*time.Millisecond)
+		assert.LessOrEqual(t, retryBackoff(RetryJitterDecorrelated, minWait, maxWait, 3, time.Second), maxWait)
+	}
+}
+
+func TestHttpCfg_retryAfter(t *testing.T) {
+	h := &HttpCfg{RetryCount: 1, RetryWaitTime: time.Millisecond, RetryMaxWaitTime: 2 * time.Second}
+	client := h.NewRestyClient()
+	resp := &resty.Response{
+		Request:     client.R(),
+		RawResponse: &http.Response{Header: http.Header{"Retry-After": {"1"}}},
+	}
+	wait, err := h.retryAfter(client, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	client.SetRetryMaxWaitTime(500 * time.Millisecond)
+	wait, err = h.retryAfter(client, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	h.RetryMaxTotalTime = 100 * time.Millisecond
+	wait, err = h.retryAfter(client, resp)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, wait, 100*time.Millisecond)
+}
+
+func TestHttpCfg_retry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/retry-after" {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	t.Run("long retry-after", func(t *testing.T) {
+		calls.Store(0)
+		client := (&HttpCfg{BaseUrl: srv.URL, RetryCount: 3, RetryMaxWaitTime: 100 * time.Millisecond}).NewRestyClient()
+		resp, err := client.R().Get("/retry-after")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode())
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("max total time", func(t *testing.T) {
+		calls.Store(0)
+		client := (&HttpCfg{
+			BaseUrl:           srv.URL,
+			RetryCount:        10,
+			RetryWaitTime:     20 * time.Millisecond,
+			RetryJitter:       RetryJitterDecorrelated,
+			RetryMaxTotalTime: 50 * time.Millisecond,
+		}).NewRestyClient()
+		start := time.Now()
+		resp, err := client.R().Get("/")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode())
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+		assert.GreaterOrEqual(t, calls.Load(), int32(2))
+		assert.Less(t, calls.Load(), int32(4))
+
+		calls.Store(0)
+		_, err = client.R().Get("/")
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, calls.Load(), int32(2))
+	})
+}
+
+func TestHttpCfg_retryCondition(t *testing.T) {
+	resp := func(method string, header http.Header) *resty.Response {
+		return &resty.Response{
+			Request:     &resty.Request{Method: method, Header: header},
+			RawResponse: &http.Response{StatusCode: http.StatusServiceUnavailable},
+		}
+	}
+	client := resty.New()
+	h := &HttpCfg{}
+	retryCondition := h.retryCondition(client)
+	assert.False(t, retryCondition(nil, nil))
+	assert.True(t, retryCondition(resp(http.MethodGet, http.Header{}), nil))
+	assert.True(t, retryCondition(resp(http.MethodDelete, http.Header{}), nil))
+	assert.False(t, retryCondition(resp(http.MethodPost, http.Header{}), nil))
+	assert.True(t, retryCondition(resp(http.MethodPost, http.Header{HeaderIdempotencyKey: {"key"}}), nil))
+	retryAfter := resp(http.MethodGet, http.Header{})
+	retryAfter.RawResponse.Header = http.Header{"Retry-After": {"3"}}
+	assert.False(t, retryCondition(retryAfter, nil))
+
+	h = &HttpCfg{RetryMethods: []string{"post"}}
+	retryCondition = h.retryCondition(client)
+	assert.True(t, retryCondition(resp(http.MethodPost, http.Header{}), nil))
+	assert.False(t, retryCondition(resp(http.MethodGet, http.Header{}), nil))
+
+	h = &HttpCfg{RetryMaxTotalTime: time.Second}
+	retryCondition = h.retryCondition(client)
+	started := resp(http.MethodGet, http.Header{})
+	started.Request.SetContext(context.WithValue(context.Background(), retryStateKey{},
+		&retryState{start: time.Now().Add(-time.Second + client.RetryWaitTime/2)}))
+	assert.False(t, retryCondition(started, nil))
+	started.Request.SetContext(context.WithValue(context.Background(), retryStateKey{},
+		&retryState{start: time.Now()}))
+	assert.True(t, retryCondition(started, nil))
+}
+
+func TestHttpCfg_idempotency(t *testing.T) {
+	var calls atomic.Int32
+	keys := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		keys <- r.Header.Get(HeaderIdempotencyKey)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	cfg := &HttpCfg{BaseUrl: srv.URL, RetryCount: 2, RetryWaitTime: time.Millisecond}
+
+	calls.Store(0)
+	_, err := cfg.NewRestyClient().R().SetBody("{}").Post("/")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, calls.Load())
+	assert.Empty(t, <-keys)
+
+	calls.Store(0)
+	_, err = cfg.NewRestyClient().R().SetHeader(HeaderIdempotencyKey, "key").SetBody("{}").Post("/")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, calls.Load())
+	for range 3 {
+		assert.Equal(t, "key", <-keys)
+	}
+
+	calls.Store(0)
+	cfg.GenIdempotencyKey = true
+	client := cfg.NewRestyClient()
+	_, err = client.R().SetBody("{}").Post("/")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, calls.Load())
+	key := <-keys
+	assert.Len(t, key, 32)
+	assert.Equal(t, key, <-keys)
+	assert.Equal(t, key, <-keys)
+
+	calls.Store(0)
+	_, err = client.R().Get("/")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, calls.Load())
+	for range 3 {
+		assert.Empty(t, <-keys)
+	}
+}
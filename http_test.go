@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -36,7 +38,8 @@ func TestHttpCfg_NewRestyClient(t *testing.T) {
 			},
 			func(t *testing.T, c *resty.Client) {
 				assert.NotNil(t, c)
-				assert.Equal(t, http.DefaultClient, c.GetClient())
+				assert.NotSame(t, http.DefaultClient, c.GetClient())
+				assert.Nil(t, http.DefaultClient.Transport)
 				assert.Equal(t, "base", c.BaseURL)
 				assert.Equal(t, []string{"value"}, c.Header["Header"])
 				assert.Equal(t, 1, c.RetryCount)
@@ -80,6 +83,68 @@ func TestHttpCfg_NewRestyClient(t *testing.T) {
 	}
 }
 
+func TestHttpCfg_NewRestyClient_sharedHttpClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	transport := &http.Transport{}
+	var stateChanges atomic.Int32
+	h := &HttpCfg{
+		HttpClient:      &http.Client{Transport: transport},
+		BaseUrl:         srv.URL,
+		MaxConnsPerHost: 1,
+		CircuitBreaker: &CircuitBreakerCfg{
+			ConsecutiveFailures: 1,
+			OnStateChange:       func(string, CircuitState, CircuitState) { stateChanges.Add(1) },
+		},
+	}
+	h.NewRestyClient()
+	client := h.NewRestyClient()
+	assert.Same(t, transport, h.HttpClient.Transport)
+	assert.Zero(t, transport.MaxConnsPerHost)
+
+	resp, err := client.R().Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+	assert.EqualValues(t, 1, stateChanges.Load())
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHttpCfg_WrapTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	var infos []HttpRequestInfo
+	client := (&HttpCfg{
+		BaseUrl: srv.URL,
+		WrapTransport: func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				info, ok := HttpRequestInfoFromCtx(req.Context())
+				assert.True(t, ok)
+				infos = append(infos, info)
+				return next.RoundTrip(req)
+			})
+		},
+	}).NewRestyClient()
+
+	_, err := client.R().SetPathParam("id", "1").Get("/users/{id}?q=1")
+	assert.NoError(t, err)
+	assert.Equal(t, []HttpRequestInfo{{URLTemplate: "/users/{id}", Attempt: 1}}, infos)
+}
+
+func Test_urlTemplate(t *testing.T) {
+	assert.Equal(t, "/users/{id}", urlTemplate("/users/{id}?q=1"))
+	assert.Equal(t, "/users/{id}", urlTemplate("users/{id}"))
+	assert.Equal(t, "/a", urlTemplate("https://host:8080/a#b"))
+	assert.Equal(t, "/", urlTemplate("https://host"))
+	assert.Equal(t, "/", urlTemplate(""))
+}
+
 func TestJSONUnmarshal(t *testing.T) {
 	type args struct {
 		data []byte
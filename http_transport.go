@@ -0,0 +1,219 @@
+package kutils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/http2"
+)
+
+const (
+	defaultDialTimeout = 30 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+)
+
+// HttpProtocol is the protocol mode of an http client.
+type HttpProtocol string
+
+const (
+	HttpProtocolDefault HttpProtocol = ""      // same as HttpProtocolH2
+	HttpProtocolHttp1   HttpProtocol = "http1" // HTTP/1.1 only
+	HttpProtocolH2      HttpProtocol = "h2"    // HTTP/2 over TLS if negotiated with ALPN, HTTP/1.1 otherwise
+	HttpProtocolH2c     HttpProtocol = "h2c"   // HTTP/2 over cleartext TCP with prior knowledge, https urls unsupported
+)
+
+// TLSCfg is the TLS configs of an http client.
+type TLSCfg struct {
+	CAFile             string // PEM bundle of CAs to verify servers with, default the system roots
+	CertFile           string // PEM client certificate for mutual TLS
+	KeyFile            string // PEM client private key for mutual TLS
+	MinVersion         string // min TLS version, "1.0", "1.1", "1.2" or "1.3", default "1.2"
+	ServerName         string // server name to verify certificates against, default the request host
+	InsecureSkipVerify bool   // whether to skip verifying server certificates, for testing only
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Config loads the configured files into a tls.Config.
+func (c *TLSCfg) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec
+	}
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, errors.Errorf("TLSCfg.Config|invalid MinVersion %q", c.MinVersion)
+		}
+		cfg.MinVersion = version
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "TLSCfg.Config|failed to read CAFile")
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("TLSCfg.Config|no certificate found in CAFile %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "TLSCfg.Config|failed to load client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// dialer returns the dialer per configured timeouts, or nil to keep the default one.
+func (h *HttpCfg) dialer() *net.Dialer {
+	if h.DialTimeout == 0 && h.KeepAlive == 0 {
+		return nil
+	}
+	dialer := &net.Dialer{Timeout: h.DialTimeout, KeepAlive: h.KeepAlive}
+	if dialer.Timeout == 0 {
+		dialer.Timeout = defaultDialTimeout
+	}
+	if dialer.KeepAlive == 0 {
+		dialer.KeepAlive = defaultKeepAlive
+	}
+	return dialer
+}
+
+// configureTransport applies the configured TLS, proxy and timeouts to transport.
+func (h *HttpCfg) configureTransport(transport *http.Transport) error {
+	if h.TLS != nil {
+		tlsConfig, err := h.TLS.Config()
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if h.Proxy != "" || h.NoProxy != "" {
+		proxyFunc, err := h.proxyFunc()
+		if err != nil {
+			return err
+		}
+		transport.Proxy = proxyFunc
+	}
+	if dialer := h.dialer(); dialer != nil {
+		transport.DialContext = dialer.DialContext
+	}
+	if h.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = h.TLSHandshakeTimeout
+	}
+	if h.IdleConnTimeout != 0 {
+		transport.IdleConnTimeout = h.IdleConnTimeout
+	}
+	return nil
+}
+
+// protocol returns the configured protocol mode, taking the deprecated UseH2c into account.
+func (h *HttpCfg) protocol() HttpProtocol {
+	if h.Protocol == HttpProtocolDefault && h.UseH2c {
+		return HttpProtocolH2c
+	}
+	return h.Protocol
+}
+
+// protocolTransport returns the transport of the configured protocol mode built upon transport, whose connection
+// pooling, timeouts, TLS and proxy settings it honours where applicable.
+func (h *HttpCfg) protocolTransport(transport *http.Transport) (http.RoundTripper, error) {
+	switch protocol := h.protocol(); protocol {
+	case HttpProtocolHttp1:
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig.NextProtos = slices.DeleteFunc(slices.Clone(transport.TLSClientConfig.NextProtos),
+				func(proto string) bool { return proto == "h2" })
+		}
+		return transport, nil
+	case HttpProtocolDefault, HttpProtocolH2:
+		if transport.TLSNextProto != nil && transport.TLSNextProto["h2"] == nil {
+			return transport, nil // HTTP/2 disabled with a non-nil TLSNextProto, as per http.Transport docs
+		}
+		// drop HTTP/2 support configured by a cloned transport, bound to the original one
+		delete(transport.TLSNextProto, "h2")
+		h2Transport, err := http2.ConfigureTransports(transport)
+		if err != nil {
+			return nil, errors.WithMessage(err, "HttpCfg.protocolTransport|failed to configure h2")
+		}
+		h.configureH2Transport(h2Transport)
+		return transport, nil
+	case HttpProtocolH2c:
+		if h.Proxy != "" || h.NoProxy != "" {
+			return nil, errors.New("HttpCfg.protocolTransport|Proxy and NoProxy are unsupported with h2c")
+		}
+		dialContext := transport.DialContext
+		if dialContext == nil {
+			dialContext = (&net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultKeepAlive}).DialContext
+		}
+		h2Transport := &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialContext(ctx, network, addr)
+			},
+			DisableCompression: transport.DisableCompression,
+			IdleConnTimeout:    transport.IdleConnTimeout,
+		}
+		if transport.MaxResponseHeaderBytes > 0 {
+			h2Transport.MaxHeaderListSize = uint32(min(transport.MaxResponseHeaderBytes, math.MaxUint32))
+		}
+		h.configureH2Transport(h2Transport)
+		return h2Transport, nil
+	default:
+		return nil, errors.Errorf("HttpCfg.protocolTransport|invalid Protocol %q", protocol)
+	}
+}
+
+// configureH2Transport applies the configured HTTP/2 health checks to transport.
+func (h *HttpCfg) configureH2Transport(transport *http2.Transport) {
+	transport.ReadIdleTimeout = h.H2ReadIdleTimeout
+	transport.PingTimeout = h.H2PingTimeout
+	transport.StrictMaxConcurrentStreams = h.H2StrictMaxStreams
+}
+
+// proxyFunc returns the proxy func of transports, using Proxy and NoProxy over their environment variables.
+func (h *HttpCfg) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	cfg := httpproxy.FromEnvironment()
+	if h.Proxy != "" {
+		if _, err := url.Parse(h.Proxy); err != nil {
+			return nil, errors.WithMessage(err, "HttpCfg.proxyFunc|invalid Proxy")
+		}
+		cfg.HTTPProxy, cfg.HTTPSProxy = h.Proxy, h.Proxy
+	}
+	if h.NoProxy != "" {
+		cfg.NoProxy = h.NoProxy
+	}
+	proxyFunc := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+// errTransport fails all requests with err, e.g. for a client with invalid configs.
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
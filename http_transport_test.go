@@ -0,0 +1,205 @@
+package kutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func writePEM(t *testing.T, path, typ string, data []byte) string {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: data}), 0o600))
+	return path
+}
+
+// newClientCert generates a self-signed client certificate and writes it and its key to dir.
+func newClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return cert, writePEM(t, filepath.Join(dir, "client.pem"), "CERTIFICATE", der),
+		writePEM(t, filepath.Join(dir, "client.key"), "EC PRIVATE KEY", keyDer)
+}
+
+func TestHttpCfg_TLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := newClientCert(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", srv.Certificate().Raw)
+
+	resp, err := (&HttpCfg{
+		BaseUrl: srv.URL,
+		TLS: &TLSCfg{
+			CAFile:     caFile,
+			CertFile:   certFile,
+			KeyFile:    keyFile,
+			MinVersion: "1.3",
+			ServerName: "example.com",
+		},
+		DialTimeout:         time.Second,
+		KeepAlive:           time.Second,
+		TLSHandshakeTimeout: time.Second,
+	}).NewRestyClient().R().Get("/")
+	require.NoError(t, err)
+	assert.Equal(t, "client", resp.String())
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.RawResponse.TLS.Version)
+
+	_, err = (&HttpCfg{BaseUrl: srv.URL, TLS: &TLSCfg{CAFile: caFile}}).NewRestyClient().R().Get("/")
+	assert.Error(t, err, "missing client certificate")
+	_, err = (&HttpCfg{BaseUrl: srv.URL, TLS: &TLSCfg{CertFile: certFile, KeyFile: keyFile}}).NewRestyClient().R().
+		Get("/")
+	assert.Error(t, err, "unknown server CA")
+	_, err = (&HttpCfg{BaseUrl: srv.URL, TLS: &TLSCfg{CAFile: filepath.Join(dir, "missing.pem")}}).NewRestyClient().
+		R().Get("/")
+	assert.ErrorContains(t, err, "failed to read CAFile")
+}
+
+func TestTLSCfg_Config(t *testing.T) {
+	cfg, err := (&TLSCfg{}).Config()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Nil(t, cfg.RootCAs)
+	assert.Empty(t, cfg.Certificates)
+
+	_, err = (&TLSCfg{MinVersion: "1.4"}).Config()
+	assert.ErrorContains(t, err, "invalid MinVersion")
+	caFile := writePEM(t, filepath.Join(t.TempDir(), "ca.pem"), "CERTIFICATE", []byte("invalid"))
+	_, err = (&TLSCfg{CAFile: caFile}).Config()
+	assert.Error(t, err)
+	_, err = (&TLSCfg{CertFile: caFile}).Config()
+	assert.ErrorContains(t, err, "failed to load client certificate")
+}
+
+func TestHttpCfg_Proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	client := (&HttpCfg{Proxy: proxy.URL, NoProxy: "direct.invalid"}).NewRestyClient()
+	resp, err := client.R().Get("http://kutils.invalid/path")
+	require.NoError(t, err)
+	assert.Equal(t, "proxied http://kutils.invalid/path", resp.String())
+	_, err = client.R().Get("http://direct.invalid/path")
+	assert.Error(t, err)
+}
+
+func TestHttpCfg_Protocol(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	tlsSrv := httptest.NewUnstartedServer(handler)
+	tlsSrv.EnableHTTP2 = true
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+	h2cSrv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer h2cSrv.Close()
+
+	tests := []struct {
+		protocol HttpProtocol
+		url      string
+		want     string
+	}{
+		{HttpProtocolDefault, tlsSrv.URL, "HTTP/2.0"},
+		{HttpProtocolH2, tlsSrv.URL, "HTTP/2.0"},
+		{HttpProtocolH2, h2cSrv.URL, "HTTP/1.1"},
+		{HttpProtocolHttp1, tlsSrv.URL, "HTTP/1.1"},
+		{HttpProtocolH2c, h2cSrv.URL, "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.protocol)+" "+tt.url, func(t *testing.T) {
+			resp, err := (&HttpCfg{
+				Protocol:          tt.protocol,
+				TLS:               &TLSCfg{InsecureSkipVerify: true},
+				H2ReadIdleTimeout: time.Second,
+				H2PingTimeout:     time.Second,
+			}).NewRestyClient().R().Get(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, resp.String())
+		})
+	}
+
+	resp, err := (&HttpCfg{
+		HttpClient: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+			TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},
+		}},
+	}).NewRestyClient().R().Get(tlsSrv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1", resp.String(), "HTTP/2 disabled by the client transport")
+
+	_, err = (&HttpCfg{Protocol: "h3"}).NewRestyClient().R().Get(tlsSrv.URL)
+	assert.ErrorContains(t, err, "invalid Protocol")
+	_, err = (&HttpCfg{Protocol: HttpProtocolH2c, Proxy: "http://proxy"}).NewRestyClient().R().Get(h2cSrv.URL)
+	assert.ErrorContains(t, err, "unsupported with h2c")
+}
+
+func TestHttpCfg_protocolTransport(t *testing.T) {
+	h := &HttpCfg{
+		IdleConnTimeout:    time.Minute,
+		Protocol:           HttpProtocolH2c,
+		H2ReadIdleTimeout:  time.Second,
+		H2PingTimeout:      2 * time.Second,
+		H2StrictMaxStreams: true,
+	}
+	transport := &http.Transport{MaxResponseHeaderBytes: 1 << 20}
+	require.NoError(t, h.configureTransport(transport))
+	rt, err := h.protocolTransport(transport)
+	require.NoError(t, err)
+	h2Transport, ok := rt.(*http2.Transport)
+	require.True(t, ok)
+	assert.True(t, h2Transport.AllowHTTP)
+	assert.Equal(t, time.Minute, h2Transport.IdleConnTimeout)
+	assert.Equal(t, time.Second, h2Transport.ReadIdleTimeout)
+	assert.Equal(t, 2*time.Second, h2Transport.PingTimeout)
+	assert.True(t, h2Transport.StrictMaxConcurrentStreams)
+	assert.EqualValues(t, 1<<20, h2Transport.MaxHeaderListSize)
+
+	h = &HttpCfg{UseH2c: true}
+	assert.Equal(t, HttpProtocolH2c, h.protocol())
+	h = &HttpCfg{Protocol: HttpProtocolHttp1, TLS: &TLSCfg{}}
+	transport = &http.Transport{ForceAttemptHTTP2: true}
+	require.NoError(t, h.configureTransport(transport))
+	transport.TLSClientConfig.NextProtos = []string{"h2", "http/1.1"}
+	rt, err = h.protocolTransport(transport)
+	require.NoError(t, err)
+	assert.Same(t, transport, rt)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+	assert.Equal(t, []string{"http/1.1"}, transport.TLSClientConfig.NextProtos)
+}
@@ -0,0 +1,87 @@
+// Package kotel instruments kutils with OpenTelemetry.
+package kotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/KyberNetwork/kutils"
+)
+
+// BatchObserver is a kutils.BatchObserver tracing ChanBatcher batches with OpenTelemetry. Each batch gets its own root
+// span linked to the spans of its tasks, and each task span gets a link back to the batch span.
+type BatchObserver struct {
+	tracer trace.Tracer
+	name   string
+}
+
+// NewBatchObserver creates a BatchObserver creating batch spans named "<name>.batch" with the given tracer.
+func NewBatchObserver(tracer trace.Tracer, name string) *BatchObserver {
+	return &BatchObserver{
+		tracer: tracer,
+		name:   name,
+	}
+}
+
+func (o *BatchObserver) OnEnqueue(ctx context.Context, queueLen int) {
+	if ctx == nil {
+		return
+	}
+	trace.SpanFromContext(ctx).AddEvent("batcher.enqueue", trace.WithAttributes(
+		attribute.String("batcher.name", o.name),
+		attribute.Int("batcher.queue_length", queueLen),
+	))
+}
+
+func (o *BatchObserver) OnDispatch(ctx context.Context, info *kutils.BatchInfo) context.Context {
+	links := make([]trace.Link, 0, len(info.TaskCtxs))
+	for _, taskCtx := range info.TaskCtxs {
+		if taskCtx == nil {
+			continue
+		}
+		if spanCtx := trace.SpanContextFromContext(taskCtx); spanCtx.IsValid() {
+			links = append(links, trace.Link{SpanContext: spanCtx})
+		}
+	}
+	ctx, span := o.tracer.Start(ctx, o.name+".batch",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("batcher.name", o.name),
+			attribute.String("batcher.trigger", string(info.Trigger)),
+			attribute.Int("batcher.batch_size", info.Size),
+			attribute.Int("batcher.queue_length", info.QueueLen),
+			attribute.Int64("batcher.wait_us", info.Wait.Microseconds()),
+		),
+	)
+	batchLink := trace.Link{SpanContext: span.SpanContext()}
+	for _, taskCtx := range info.TaskCtxs {
+		if taskCtx == nil {
+			continue
+		}
+		if taskSpan := trace.SpanFromContext(taskCtx); taskSpan.SpanContext().IsValid() {
+			taskSpan.AddLink(batchLink)
+		}
+	}
+	return ctx
+}
+
+func (o *BatchObserver) OnPanic(ctx context.Context, _ *kutils.BatchInfo, p any) {
+	span := trace.SpanFromContext(ctx)
+	err, ok := p.(error)
+	if !ok {
+		err = errors.Errorf("%v", p)
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "batchFn panicked")
+}
+
+func (o *BatchObserver) OnComplete(ctx context.Context, _ *kutils.BatchInfo, _ time.Duration) {
+	trace.SpanFromContext(ctx).End()
+}
@@ -0,0 +1,52 @@
+package kotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/KyberNetwork/kutils"
+)
+
+func TestBatchObserver(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	observer := NewBatchObserver(tracer, "pools")
+	batcher := kutils.NewChanBatcher[*kutils.ChanTask[int], int](func() (time.Duration, int) { return time.Hour, 2 },
+		func(tasks []*kutils.ChanTask[int]) {
+			for _, task := range tasks {
+				task.Resolve(0, nil)
+			}
+		}, kutils.WithBatchObserver(observer))
+
+	ctx0, span0 := tracer.Start(context.Background(), "task0")
+	ctx1, span1 := tracer.Start(context.Background(), "task1")
+	task0, task1 := kutils.NewChanTask[int](ctx0), kutils.NewChanTask[int](ctx1)
+	batcher.Batch(task0)
+	batcher.Batch(task1)
+	_, _ = task0.Result()
+	_, _ = task1.Result()
+	batcher.Close()
+	assert.Eventually(t, func() bool { return len(recorder.Ended()) == 1 }, time.Second, time.Millisecond)
+	span0.End()
+	span1.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	batchSpan := spans[0]
+	assert.Equal(t, "pools.batch", batchSpan.Name())
+	assert.False(t, batchSpan.Parent().IsValid())
+	require.Len(t, batchSpan.Links(), 2)
+	assert.Equal(t, span0.SpanContext(), batchSpan.Links()[0].SpanContext)
+	assert.Equal(t, span1.SpanContext(), batchSpan.Links()[1].SpanContext)
+	for _, taskSpan := range spans[1:] {
+		require.Len(t, taskSpan.Links(), 1)
+		assert.Equal(t, batchSpan.SpanContext(), taskSpan.Links()[0].SpanContext)
+		assert.Len(t, taskSpan.Events(), 1)
+	}
+}
@@ -0,0 +1,234 @@
+package kotel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/KyberNetwork/kutils"
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+const instrumentationName = "github.com/KyberNetwork/kutils/kotel"
+
+// HttpCfg configures OpenTelemetry instrumentation of kutils resty clients, see WrapHttpTransport. Each request attempt
+// gets a client span whose trace context is propagated to the server, and records the http.client.request.duration
+// histogram, plus the http.client.request.retries counter for retries. Metrics are labeled by method, status, server
+// and url.template, whose cardinality is bounded by MaxRoutes. As urls may contain api keys in their userinfo, path or
+// query, the url.full attribute of spans only keeps the scheme and host of the url, followed by its url.template.
+type HttpCfg struct {
+	TracerProvider trace.TracerProvider          `json:"-"` // default otel.GetTracerProvider()
+	MeterProvider  metric.MeterProvider          `json:"-"` // default otel.GetMeterProvider()
+	Propagator     propagation.TextMapPropagator `json:"-"` // default W3C trace context and baggage
+	// Route returns the url.template of a request, default the URLTemplate of its kutils.HttpRequestInfo, e.g.
+	// "/users/{id}"
+	Route     func(req *http.Request) string `json:"-"`
+	MaxRoutes int                            // max distinct url.template labels, others are labeled "_OTHER", default 100
+}
+
+func (c HttpCfg) withDefaults() HttpCfg {
+	if c.TracerProvider == nil {
+		c.TracerProvider = otel.GetTracerProvider()
+	}
+	if c.MeterProvider == nil {
+		c.MeterProvider = otel.GetMeterProvider()
+	}
+	if c.Propagator == nil {
+		c.Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+	if c.Route == nil {
+		c.Route = otelRoute
+	}
+	if c.MaxRoutes <= 0 {
+		c.MaxRoutes = 100
+	}
+	return c
+}
+
+func otelRoute(req *http.Request) string {
+	info, _ := kutils.HttpRequestInfoFromCtx(req.Context())
+	return info.URLTemplate
+}
+
+// otelTransport traces and measures requests with OpenTelemetry.
+type otelTransport struct {
+	next     http.RoundTripper
+	cfg      HttpCfg
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	retries  metric.Int64Counter
+	routesMu sync.RWMutex
+	routes   map[string]struct{}
+}
+
+// WrapHttpTransport returns a kutils.HttpCfg WrapTransport instrumenting requests per cfg.
+func WrapHttpTransport(cfg HttpCfg) func(next http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return newOtelTransport(next, cfg)
+	}
+}
+
+func newOtelTransport(next http.RoundTripper, cfg HttpCfg) *otelTransport {
+	cfg = cfg.withDefaults()
+	meter := cfg.MeterProvider.Meter(instrumentationName)
+	duration, err := meter.Float64Histogram("http.client.request.duration",
+		metric.WithDescription("Duration of HTTP client requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10),
+	)
+	if err != nil {
+		klog.Errorf(context.Background(), "newOtelTransport|failed to create duration histogram: %v", err)
+		duration = noop.Float64Histogram{}
+	}
+	retries, err := meter.Int64Counter("http.client.request.retries",
+		metric.WithDescription("Number of HTTP client request retries."),
+		metric.WithUnit("{retry}"),
+	)
+	if err != nil {
+		klog.Errorf(context.Background(), "newOtelTransport|failed to create retries counter: %v", err)
+		retries = noop.Int64Counter{}
+	}
+	return &otelTransport{
+		next:     next,
+		cfg:      cfg,
+		tracer:   cfg.TracerProvider.Tracer(instrumentationName),
+		duration: duration,
+		retries:  retries,
+		routes:   make(map[string]struct{}),
+	}
+}
+
+func (t *otelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	info, _ := kutils.HttpRequestInfoFromCtx(req.Context())
+	template := t.cfg.Route(req)
+	route := t.route(template)
+	attrs := []attribute.KeyValue{otelMethod(req.Method), semconv.ServerAddress(req.URL.Hostname())}
+	if port := otelPort(req); port > 0 {
+		attrs = append(attrs, semconv.ServerPort(port))
+	}
+	if route != "" {
+		attrs = append(attrs, semconv.URLTemplate(route))
+	}
+
+	spanName := req.Method
+	if route != "" {
+		spanName += " " + route
+	}
+	spanAttrs := append(slices.Clip(attrs), semconv.URLFull(otelURL(req.URL, template)))
+	if info.Attempt > 1 {
+		spanAttrs = append(spanAttrs, semconv.HTTPRequestResendCount(info.Attempt-1))
+		t.retries.Add(req.Context(), 1, metric.WithAttributes(attrs...))
+	}
+	ctx, span := t.tracer.Start(req.Context(), spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(spanAttrs...),
+	)
+	req = req.Clone(ctx)
+	t.cfg.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		errType := fmt.Sprintf("%T", err)
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errType))
+		span.SetAttributes(semconv.ErrorTypeKey.String(errType))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+			span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+	}
+	t.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+
+	if err != nil || resp.Body == nil {
+		span.End()
+		return resp, err
+	}
+	resp.Body = &endSpanOnCloseBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// endSpanOnCloseBody ends a span once a response body is closed, i.e. once the request is over.
+type endSpanOnCloseBody struct {
+	io.ReadCloser
+	once sync.Once
+	span trace.Span
+}
+
+func (b *endSpanOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.span.End() })
+	return err
+}
+
+// route returns the url.template label of a request, or "_OTHER" once MaxRoutes distinct templates have been seen.
+func (t *otelTransport) route(route string) string {
+	if route == "" {
+		return ""
+	}
+	t.routesMu.RLock()
+	_, ok := t.routes[route]
+	t.routesMu.RUnlock()
+	if ok {
+		return route
+	}
+	t.routesMu.Lock()
+	defer t.routesMu.Unlock()
+	if _, ok = t.routes[route]; ok {
+		return route
+	} else if len(t.routes) >= t.cfg.MaxRoutes {
+		return "_OTHER"
+	}
+	t.routes[route] = struct{}{}
+	return route
+}
+
+// otelMethod returns the http.request.method attribute, with non-standard methods as "_OTHER" to bound cardinality.
+func otelMethod(method string) attribute.KeyValue {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return semconv.HTTPRequestMethodKey.String(method)
+	default:
+		return semconv.HTTPRequestMethodOther
+	}
+}
+
+func otelPort(req *http.Request) int {
+	if port, err := strconv.Atoi(req.URL.Port()); err == nil {
+		return port
+	}
+	switch req.URL.Scheme {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	default:
+		return 0
+	}
+}
+
+// otelURL returns the scheme and host of a url followed by its template, leaving out any credentials or api keys.
+func otelURL(u *url.URL, template string) string {
+	return u.Scheme + "://" + u.Host + template
+}
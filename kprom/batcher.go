@@ -0,0 +1,114 @@
+// Package kprom exports metrics of kutils to Prometheus.
+package kprom
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/KyberNetwork/kutils"
+)
+
+// BatchObserver is a kutils.BatchObserver exporting ChanBatcher metrics to prometheus. Metrics of different batchers
+// share the same names and are distinguished by the "batcher" label.
+type BatchObserver struct {
+	tasks     prometheus.Counter
+	queueLen  prometheus.Gauge
+	batches   *prometheus.CounterVec
+	batchSize prometheus.Histogram
+	wait      prometheus.Histogram
+	duration  prometheus.Histogram
+	panics    prometheus.Counter
+}
+
+// NewBatchObserver creates a BatchObserver for the batcher with the given name and registers its metrics to registerer
+// (prometheus.DefaultRegisterer if nil).
+func NewBatchObserver(registerer prometheus.Registerer, namespace, name string) (*BatchObserver, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	constLabels := prometheus.Labels{"batcher": name}
+	o := &BatchObserver{
+		tasks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "batcher",
+			Name:        "tasks_total",
+			Help:        "Number of tasks submitted to the batcher.",
+			ConstLabels: constLabels,
+		}),
+		queueLen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "batcher",
+			Name:        "queue_length",
+			Help:        "Number of tasks queued in the batcher waiting to be dispatched.",
+			ConstLabels: constLabels,
+		}),
+		batches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "batcher",
+			Name:        "batches_total",
+			Help:        "Number of batches dispatched by the batcher by trigger reason.",
+			ConstLabels: constLabels,
+		}, []string{"trigger"}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "batcher",
+			Name:        "batch_size",
+			Help:        "Number of tasks per dispatched batch.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(1, 2, 12),
+		}),
+		wait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "batcher",
+			Name:        "wait_seconds",
+			Help:        "How long the first task of a batch lingered in the batcher before being dispatched.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "batcher",
+			Name:        "batch_duration_seconds",
+			Help:        "Execution duration of batchFn.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 16),
+		}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "batcher",
+			Name:        "panics_total",
+			Help:        "Number of batchFn panics.",
+			ConstLabels: constLabels,
+		}),
+	}
+	for _, collector := range []prometheus.Collector{o.tasks, o.queueLen, o.batches, o.batchSize, o.wait, o.duration,
+		o.panics} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func (o *BatchObserver) OnEnqueue(_ context.Context, queueLen int) {
+	o.tasks.Inc()
+	o.queueLen.Set(float64(queueLen))
+}
+
+func (o *BatchObserver) OnDispatch(ctx context.Context, info *kutils.BatchInfo) context.Context {
+	o.queueLen.Set(float64(info.QueueLen))
+	o.batches.WithLabelValues(string(info.Trigger)).Inc()
+	o.batchSize.Observe(float64(info.Size))
+	o.wait.Observe(info.Wait.Seconds())
+	return ctx
+}
+
+func (o *BatchObserver) OnPanic(context.Context, *kutils.BatchInfo, any) {
+	o.panics.Inc()
+}
+
+func (o *BatchObserver) OnComplete(_ context.Context, _ *kutils.BatchInfo, duration time.Duration) {
+	o.duration.Observe(duration.Seconds())
+}
@@ -0,0 +1,59 @@
+package kprom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/kutils"
+)
+
+func TestBatchObserver(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	observer, err := NewBatchObserver(registry, "test", "pools")
+	require.NoError(t, err)
+	_, err = NewBatchObserver(registry, "test", "pools")
+	assert.Error(t, err)
+	_, err = NewBatchObserver(registry, "test", "tokens")
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	observer.OnEnqueue(ctx, 1)
+	observer.OnEnqueue(ctx, 2)
+	info := &kutils.BatchInfo{Trigger: kutils.BatchTriggerMax, Size: 2, Wait: time.Millisecond}
+	ctx = observer.OnDispatch(ctx, info)
+	observer.OnPanic(ctx, info, "test panic")
+	observer.OnComplete(ctx, info, time.Millisecond)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if metric.GetLabel()[0].GetValue() != "pools" {
+				continue
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				values[family.GetName()] += metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				values[family.GetName()] = metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				values[family.GetName()] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"test_batcher_tasks_total":            2,
+		"test_batcher_queue_length":           0,
+		"test_batcher_batches_total":          1,
+		"test_batcher_batch_size":             1,
+		"test_batcher_wait_seconds":           1,
+		"test_batcher_batch_duration_seconds": 1,
+		"test_batcher_panics_total":           1,
+	}, values)
+}
@@ -1,5 +1,11 @@
 package kutils
 
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
 func Map[T any, K comparable, V any](lst []T, keyFn func(T) K, valFn func(T) V) map[K]V {
 	res := make(map[K]V, len(lst))
 	for _, elem := range lst {
@@ -31,3 +37,47 @@ func MapKeyMulti[T any, K comparable](lst []T, keyFn func(T) K) map[K][]T {
 	}
 	return res
 }
+
+// SortedKeys returns the keys of m in ascending order.
+func SortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// MapValuesSorted returns the values of m in ascending order of their keys.
+func MapValuesSorted[K cmp.Ordered, V any](m map[K]V) []V {
+	keys := SortedKeys(m)
+	vals := make([]V, len(keys))
+	for i, key := range keys {
+		vals[i] = m[key]
+	}
+	return vals
+}
+
+// MergeMaps merges ms into a new map, calling resolve to decide the value of a key present in several maps from its
+// value merged so far and its value in the next map. The last value wins if resolve is nil.
+func MergeMaps[K comparable, V any](resolve func(key K, merged, next V) V, ms ...map[K]V) map[K]V {
+	var n int
+	for _, m := range ms {
+		n = max(n, len(m))
+	}
+	res := make(map[K]V, n)
+	for _, m := range ms {
+		for key, val := range m {
+			if merged, ok := res[key]; ok && resolve != nil {
+				val = resolve(key, merged, val)
+			}
+			res[key] = val
+		}
+	}
+	return res
+}
+
+// KeepFirst is a MergeMaps conflict resolution keeping the first value of a key.
+func KeepFirst[K comparable, V any](_ K, merged, _ V) V {
+	return merged
+}
+
+// KeepLast is a MergeMaps conflict resolution keeping the last value of a key.
+func KeepLast[K comparable, V any](_ K, _, next V) V {
+	return next
+}
@@ -3,6 +3,8 @@ package kutils
 import (
 	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMap(t *testing.T) {
@@ -74,3 +76,24 @@ func testMapKeyMulti[T any, K comparable](t *testing.T, name string, lst []T, ke
 		}
 	})
 }
+
+func TestSortedKeys(t *testing.T) {
+	m := map[string]int{"b": 1, "c": 2, "a": 3}
+	assert.Equal(t, []string{"a", "b", "c"}, SortedKeys(m))
+	assert.Equal(t, []int{3, 1, 2}, MapValuesSorted(m))
+	assert.Empty(t, SortedKeys(map[int]int(nil)))
+}
+
+func TestMergeMaps(t *testing.T) {
+	m1 := map[string]int{"a": 1, "b": 2}
+	m2 := map[string]int{"b": 3, "c": 4}
+	m3 := map[string]int{"b": 5}
+	assert.Equal(t, map[string]int{"a": 1, "b": 5, "c": 4}, MergeMaps(nil, m1, m2, m3))
+	assert.Equal(t, map[string]int{"a": 1, "b": 5, "c": 4}, MergeMaps(KeepLast, m1, m2, m3))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 4}, MergeMaps(KeepFirst, m1, m2, m3))
+	assert.Equal(t, map[string]int{"a": 1, "b": 10, "c": 4}, MergeMaps(func(_ string, merged, next int) int {
+		return merged + next
+	}, m1, m2, m3))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, m1)
+	assert.Empty(t, MergeMaps[string, int](nil))
+}
@@ -0,0 +1,214 @@
+package kutils
+
+import (
+	"bytes"
+	"encoding"
+	stdjson "encoding/json"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/KyberNetwork/kutils/internal/json"
+)
+
+// OrderedMap is a map keeping its keys in insertion order, which it iterates and marshals to JSON in. Like with
+// encoding/json maps, JSON keys are supported for string and integer kinds and encoding.TextMarshaler types.
+// Deleting a key is O(n). The zero OrderedMap is ready to use.
+type OrderedMap[K comparable, V any] struct {
+	keys []K
+	vals map[K]V
+}
+
+func NewOrderedMap[K comparable, V any](capacity int) *OrderedMap[K, V] {
+	return &OrderedMap[K, V]{
+		keys: make([]K, 0, capacity),
+		vals: make(map[K]V, capacity),
+	}
+}
+
+// MapOrdered is Map keeping the order of lst. A key appearing multiple times keeps its first position and last value.
+func MapOrdered[T any, K comparable, V any](lst []T, keyFn func(T) K, valFn func(T) V) *OrderedMap[K, V] {
+	res := NewOrderedMap[K, V](len(lst))
+	for _, elem := range lst {
+		res.Set(keyFn(elem), valFn(elem))
+	}
+	return res
+}
+
+func (m *OrderedMap[K, V]) Get(key K) (V, bool) {
+	val, ok := m.vals[key]
+	return val, ok
+}
+
+func (m *OrderedMap[K, V]) Has(key K) bool {
+	_, ok := m.vals[key]
+	return ok
+}
+
+// Set sets the value of key, appending key if absent, or keeping its position otherwise.
+func (m *OrderedMap[K, V]) Set(key K, val V) {
+	if m.vals == nil {
+		m.vals = make(map[K]V)
+	}
+	if _, ok := m.vals[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.vals[key] = val
+}
+
+func (m *OrderedMap[K, V]) Delete(key K) {
+	if _, ok := m.vals[key]; !ok {
+		return
+	}
+	delete(m.vals, key)
+	m.keys = slices.DeleteFunc(m.keys, func(k K) bool { return k == key })
+}
+
+func (m *OrderedMap[K, V]) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns a copy of the keys in insertion order.
+func (m *OrderedMap[K, V]) Keys() []K {
+	return slices.Clone(m.keys)
+}
+
+// Values returns the values in insertion order of their keys.
+func (m *OrderedMap[K, V]) Values() []V {
+	vals := make([]V, len(m.keys))
+	for i, key := range m.keys {
+		vals[i] = m.vals[key]
+	}
+	return vals
+}
+
+// All iterates over keys and values in insertion order.
+func (m *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, key := range m.keys {
+			if !yield(key, m.vals[key]) {
+				return
+			}
+		}
+	}
+}
+
+// Map returns the key values as a plain map.
+func (m *OrderedMap[K, V]) Map() map[K]V {
+	return maps.Clone(m.vals)
+}
+
+// MarshalJSON marshals m as a JSON object in insertion order. It has a value receiver so that OrderedMap fields held by
+// value marshal as well.
+func (m OrderedMap[K, V]) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyStr, err := marshalJSONKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keyData, err := json.Marshal(keyStr)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyData)
+		buf.WriteByte(':')
+		valData, err := json.Marshal(m.vals[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(valData)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON replaces the content of m with a JSON object, keeping the order of its keys.
+func (m *OrderedMap[K, V]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	decoder := stdjson.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return err
+	} else if token != stdjson.Delim('{') {
+		return errors.Errorf("OrderedMap.UnmarshalJSON|expect object, got %v", token)
+	}
+	res := OrderedMap[K, V]{vals: make(map[K]V)}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, err := unmarshalJSONKey[K](token.(string))
+		if err != nil {
+			return err
+		}
+		var raw stdjson.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			return err
+		}
+		var val V
+		if err = json.Unmarshal(raw, &val); err != nil {
+			return err
+		}
+		res.Set(key, val)
+	}
+	*m = res
+	return nil
+}
+
+// marshalJSONKey converts a map key to a JSON object key per encoding/json rules.
+func marshalJSONKey[K comparable](key K) (string, error) {
+	if tm, ok := any(key).(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	rv := reflect.ValueOf(key)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	default:
+		return "", errors.Errorf("OrderedMap.MarshalJSON|unsupported key type %T", key)
+	}
+}
+
+// unmarshalJSONKey converts a JSON object key to a map key per encoding/json rules.
+func unmarshalJSONKey[K comparable](keyStr string) (key K, err error) {
+	if tu, ok := any(&key).(encoding.TextUnmarshaler); ok {
+		err = tu.UnmarshalText([]byte(keyStr))
+		return key, err
+	}
+	rv := reflect.ValueOf(&key).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(keyStr)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(keyStr, 10, rv.Type().Bits())
+		if err != nil {
+			return key, err
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(keyStr, 10, rv.Type().Bits())
+		if err != nil {
+			return key, err
+		}
+		rv.SetUint(u)
+	default:
+		return key, errors.Errorf("OrderedMap.UnmarshalJSON|unsupported key type %T", key)
+	}
+	return key, nil
+}
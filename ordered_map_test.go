@@ -0,0 +1,104 @@
+package kutils
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/kutils/internal/json"
+)
+
+func TestOrderedMap(t *testing.T) {
+	var m OrderedMap[string, int]
+	m.Set("c", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+	m.Set("a", 4)
+	assert.Equal(t, 3, m.Len())
+	assert.Equal(t, []string{"c", "a", "b"}, m.Keys())
+	assert.Equal(t, []int{1, 4, 3}, m.Values())
+	val, ok := m.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 4, val)
+	assert.True(t, m.Has("b"))
+
+	m.Delete("a")
+	m.Delete("d")
+	assert.False(t, m.Has("a"))
+	assert.Equal(t, []string{"c", "b"}, m.Keys())
+	assert.Equal(t, map[string]int{"c": 1, "b": 3}, m.Map())
+	var keys []string
+	for key := range m.All() {
+		keys = append(keys, key)
+		break
+	}
+	assert.Equal(t, []string{"c"}, keys)
+
+	ordered := MapOrdered([]int{-3, 1, 3, -1, 2}, Abs[int], Itoa[int])
+	assert.Equal(t, []int{3, 1, 2}, ordered.Keys())
+	assert.Equal(t, []string{"3", "-1", "2"}, ordered.Values())
+}
+
+func TestOrderedMap_json(t *testing.T) {
+	t.Run("string keys", func(t *testing.T) {
+		m := NewOrderedMap[string, []int](2)
+		m.Set("z", []int{1})
+		m.Set("a", nil)
+		m.Set(`"q"`, []int{})
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		assert.Equal(t, `{"z":[1],"a":null,"\"q\"":[]}`, string(data))
+
+		var decoded OrderedMap[string, []int]
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, m.Keys(), decoded.Keys())
+		assert.Equal(t, m.Values(), decoded.Values())
+	})
+
+	t.Run("value field", func(t *testing.T) {
+		var resp struct {
+			M OrderedMap[string, int]
+			P *OrderedMap[string, int]
+		}
+		resp.M.Set("b", 2)
+		resp.M.Set("a", 1)
+		data, err := json.Marshal(resp)
+		require.NoError(t, err)
+		assert.Equal(t, `{"M":{"b":2,"a":1},"P":null}`, string(data))
+	})
+
+	t.Run("integer keys", func(t *testing.T) {
+		var m OrderedMap[int8, string]
+		require.NoError(t, json.Unmarshal([]byte(`{"3":"c","-1":"a"}`), &m))
+		assert.Equal(t, []int8{3, -1}, m.Keys())
+		data, err := json.Marshal(&m)
+		require.NoError(t, err)
+		assert.Equal(t, `{"3":"c","-1":"a"}`, string(data))
+		assert.Error(t, json.Unmarshal([]byte(`{"300":"c"}`), &m))
+	})
+
+	t.Run("text keys", func(t *testing.T) {
+		var m OrderedMap[netip.Addr, bool]
+		require.NoError(t, json.Unmarshal([]byte(`{"10.0.0.2":true,"10.0.0.1":false}`), &m))
+		assert.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.1")}, m.Keys())
+		data, err := json.Marshal(&m)
+		require.NoError(t, err)
+		assert.Equal(t, `{"10.0.0.2":true,"10.0.0.1":false}`, string(data))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var m OrderedMap[string, int]
+		assert.Error(t, json.Unmarshal([]byte(`[1]`), &m))
+		assert.Error(t, json.Unmarshal([]byte(`{"a":"b"}`), &m))
+		require.NoError(t, json.Unmarshal([]byte(`null`), &m))
+		assert.Zero(t, m.Len())
+
+		var unsupported OrderedMap[float64, int]
+		unsupported.Set(1.5, 1)
+		_, err := json.Marshal(&unsupported)
+		assert.Error(t, err)
+		assert.Equal(t, []float64{1.5}, unsupported.Keys())
+	})
+}
@@ -0,0 +1,103 @@
+package kutils
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+// ParallelSliceMap is SliceMap calling fn on at most concurrency goroutines (GOMAXPROCS if concurrency <= 0), e.g. for
+// IO-bound transforms. Results keep the order of lst. A panic of fn is re-raised in the caller's goroutine.
+func ParallelSliceMap[T, R any](lst []T, concurrency int, fn func(T) R) []R {
+	ret := make([]R, len(lst))
+	if err := parallelFor(context.Background(), len(lst), concurrency, func(_ context.Context, i int) error {
+		ret[i] = fn(lst[i])
+		return nil
+	}); err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// ParallelFilter returns elements of lst satisfying filter, called on at most concurrency goroutines (GOMAXPROCS if
+// concurrency <= 0). Elements keep the order of lst. A panic of filter is re-raised in the caller's goroutine.
+func ParallelFilter[T any](lst []T, concurrency int, filter func(T) bool) []T {
+	keep := ParallelSliceMap(lst, concurrency, filter)
+	ret := make([]T, 0, len(lst))
+	for i, elem := range lst {
+		if keep[i] {
+			ret = append(ret, elem)
+		}
+	}
+	return ret
+}
+
+// ParallelSliceMapErr is ParallelSliceMap with a context and an error. It stops calling fn at the first error (or
+// panic, which is converted to an error) or once ctx is done, cancelling the context passed to pending fn calls, and
+// returns that error.
+func ParallelSliceMapErr[T, R any](ctx context.Context, lst []T, concurrency int,
+	fn func(ctx context.Context, elem T) (R, error)) ([]R, error) {
+	ret := make([]R, len(lst))
+	if err := parallelFor(ctx, len(lst), concurrency, func(ctx context.Context, i int) (err error) {
+		ret[i], err = fn(ctx, lst[i])
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// parallelFor calls fn for each index in [0, n) on at most concurrency goroutines (GOMAXPROCS if concurrency <= 0),
+// stopping at the first error or panic of fn or once ctx is done, and returns the corresponding error.
+func parallelFor(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if n == 0 {
+		return nil
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var next atomic.Int64
+	var failOnce sync.Once
+	var err error
+	fail := func(e error) {
+		failOnce.Do(func() {
+			err = e
+			cancel(e)
+		})
+	}
+
+	var wg sync.WaitGroup
+	for range min(concurrency, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if p := recover(); p != nil {
+					klog.Errorf(ctx, "parallelFor|recovered from panic: %v\n%s", p, string(debug.Stack()))
+					fail(panicErr(p, "fn"))
+				}
+			}()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				if e := fn(ctx, i); e != nil {
+					fail(e)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err == nil && int(next.Load()) < n {
+		return context.Cause(ctx)
+	}
+	return err
+}
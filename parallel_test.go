@@ -0,0 +1,92 @@
+package kutils
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelSliceMap(t *testing.T) {
+	lst := []int{5, 1, 4, 2, 3}
+	var running, maxRunning atomic.Int32
+	ret := ParallelSliceMap(lst, 2, func(i int) string {
+		cnt := running.Add(1)
+		defer running.Add(-1)
+		for prev := maxRunning.Load(); cnt > prev && !maxRunning.CompareAndSwap(prev, cnt); {
+			prev = maxRunning.Load()
+		}
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		return strconv.Itoa(i)
+	})
+	assert.Equal(t, []string{"5", "1", "4", "2", "3"}, ret)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+	assert.Empty(t, ParallelSliceMap([]int{}, 0, strconv.Itoa))
+
+	assert.PanicsWithError(t, "fn panicked: test panic", func() {
+		ParallelSliceMap(lst, 0, func(int) int { panic("test panic") })
+	})
+}
+
+func TestParallelFilter(t *testing.T) {
+	assert.Equal(t, []int{2, 4, 6}, ParallelFilter([]int{1, 2, 3, 4, 5, 6}, 3, func(i int) bool { return i%2 == 0 }))
+	assert.Empty(t, ParallelFilter([]int{1, 3}, 3, func(i int) bool { return i%2 == 0 }))
+}
+
+func TestParallelSliceMapErr(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("happy", func(t *testing.T) {
+		ret, err := ParallelSliceMapErr(ctx, []int{1, 2, 3}, 0, func(_ context.Context, i int) (int, error) {
+			return i * 2, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{2, 4, 6}, ret)
+	})
+
+	t.Run("abort on error", func(t *testing.T) {
+		errTest := errors.New("test")
+		var calls atomic.Int32
+		ret, err := ParallelSliceMapErr(ctx, make([]int, 100), 2, func(ctx context.Context, i int) (int, error) {
+			if calls.Add(1) == 3 {
+				return 0, errTest
+			}
+			return i, nil
+		})
+		assert.ErrorIs(t, err, errTest)
+		assert.Nil(t, ret)
+		assert.Less(t, calls.Load(), int32(10))
+	})
+
+	t.Run("cancel pending calls", func(t *testing.T) {
+		errTest := errors.New("test")
+		_, err := ParallelSliceMapErr(ctx, []int{0, 1}, 2, func(ctx context.Context, i int) (int, error) {
+			if i == 0 {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			}
+			return 0, errTest
+		})
+		assert.ErrorIs(t, err, errTest)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		_, err := ParallelSliceMapErr(ctx, []int{1}, 1, func(context.Context, int) (int, error) {
+			panic(errors.New("test panic"))
+		})
+		assert.ErrorContains(t, err, "fn panicked: test panic")
+	})
+
+	t.Run("ctx done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := ParallelSliceMapErr(ctx, []int{1}, 1, func(_ context.Context, i int) (int, error) {
+			return i, nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,82 @@
+package kutils
+
+import "iter"
+
+// SliceMapSeq is SliceMap for iterators, e.g. SliceMapSeq(slices.Values(lst), fn).
+func SliceMapSeq[T, R any](seq iter.Seq[T], fn func(T) R) iter.Seq[R] {
+	return func(yield func(R) bool) {
+		for elem := range seq {
+			if !yield(fn(elem)) {
+				return
+			}
+		}
+	}
+}
+
+// FilterSeq yields elements of seq satisfying all filters.
+func FilterSeq[T any](seq iter.Seq[T], filters ...func(elem T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+	outer:
+		for elem := range seq {
+			for _, filter := range filters {
+				if !filter(elem) {
+					continue outer
+				}
+			}
+			if !yield(elem) {
+				return
+			}
+		}
+	}
+}
+
+// ChunkSeq is Chunk for iterators. Each yielded chunk is a newly allocated slice.
+func ChunkSeq[T any](seq iter.Seq[T], chunkSize int) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		if chunkSize < 1 {
+			return
+		}
+		var chunk []T
+		for elem := range seq {
+			if chunk == nil {
+				chunk = make([]T, 0, chunkSize)
+			}
+			if chunk = append(chunk, elem); len(chunk) == chunkSize {
+				if !yield(chunk) {
+					return
+				}
+				chunk = nil
+			}
+		}
+		if len(chunk) > 0 {
+			yield(chunk)
+		}
+	}
+}
+
+// UniqueSeq yields elements of seq skipping those already yielded.
+func UniqueSeq[T comparable](seq iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		set := make(map[T]struct{})
+		for elem := range seq {
+			if _, ok := set[elem]; ok {
+				continue
+			}
+			set[elem] = struct{}{}
+			if !yield(elem) {
+				return
+			}
+		}
+	}
+}
+
+// MapKeySeq is MapKey for iterators, yielding each element keyed by keyFn, e.g. maps.Collect(MapKeySeq(seq, keyFn)).
+func MapKeySeq[T any, K comparable](seq iter.Seq[T], keyFn func(T) K) iter.Seq2[K, T] {
+	return func(yield func(K, T) bool) {
+		for elem := range seq {
+			if !yield(keyFn(elem), elem) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package kutils
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSliceMapSeq(t *testing.T) {
+	assert.Equal(t, []string{"1", "2", "3"}, slices.Collect(SliceMapSeq(slices.Values([]int{1, 2, 3}), strconv.Itoa)))
+	for s := range SliceMapSeq(slices.Values([]int{1, 2, 3}), strconv.Itoa) {
+		assert.Equal(t, "1", s)
+		break
+	}
+}
+
+func TestFilterSeq(t *testing.T) {
+	isEven := func(i int) bool { return i%2 == 0 }
+	isPositive := func(i int) bool { return i > 0 }
+	lst := []int{-2, -1, 0, 1, 2, 3, 4}
+	assert.Equal(t, lst, slices.Collect(FilterSeq(slices.Values(lst))))
+	assert.Equal(t, []int{2, 4}, slices.Collect(FilterSeq(slices.Values(lst), isEven, isPositive)))
+	for i := range FilterSeq(slices.Values(lst), isEven) {
+		assert.Equal(t, -2, i)
+		break
+	}
+}
+
+func TestChunkSeq(t *testing.T) {
+	lst := []int{1, 2, 3, 4, 5}
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, slices.Collect(ChunkSeq(slices.Values(lst), 2)))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}}, slices.Collect(ChunkSeq(slices.Values(lst), 5)))
+	assert.Empty(t, slices.Collect(ChunkSeq(slices.Values(lst), 0)))
+	assert.Empty(t, slices.Collect(ChunkSeq(slices.Values([]int{}), 2)))
+	for chunk := range ChunkSeq(slices.Values(lst), 2) {
+		assert.Equal(t, []int{1, 2}, chunk)
+		break
+	}
+}
+
+func TestUniqueSeq(t *testing.T) {
+	assert.Equal(t, []int{3, 1, 2}, slices.Collect(UniqueSeq(slices.Values([]int{3, 1, 3, 2, 1}))))
+}
+
+func TestMapKeySeq(t *testing.T) {
+	lst := []int{1, 2, 3}
+	assert.Equal(t, map[string]int{"1": 1, "2": 2, "3": 3}, maps.Collect(MapKeySeq(slices.Values(lst), strconv.Itoa)))
+	assert.Equal(t, MapKey(lst, strconv.Itoa), maps.Collect(MapKeySeq(slices.Values(lst), strconv.Itoa)))
+}
@@ -0,0 +1,227 @@
+package kutils
+
+import (
+	"iter"
+	"maps"
+	"sync"
+
+	"github.com/KyberNetwork/kutils/internal/json"
+)
+
+// Set is a set of comparable elements. It marshals to and from a JSON array, in no particular order.
+type Set[T comparable] map[T]struct{}
+
+// NewSet creates a Set of the given elements, e.g. NewSet(lst...).
+func NewSet[T comparable](elems ...T) Set[T] {
+	s := make(Set[T], len(elems))
+	s.Add(elems...)
+	return s
+}
+
+// SetOf creates a Set of the elements of seq, e.g. SetOf(maps.Keys(m)).
+func SetOf[T comparable](seq iter.Seq[T]) Set[T] {
+	s := make(Set[T])
+	for elem := range seq {
+		s[elem] = struct{}{}
+	}
+	return s
+}
+
+func (s Set[T]) Add(elems ...T) {
+	for _, elem := range elems {
+		s[elem] = struct{}{}
+	}
+}
+
+func (s Set[T]) Remove(elems ...T) {
+	for _, elem := range elems {
+		delete(s, elem)
+	}
+}
+
+func (s Set[T]) Has(elem T) bool {
+	_, ok := s[elem]
+	return ok
+}
+
+func (s Set[T]) Len() int {
+	return len(s)
+}
+
+func (s Set[T]) Clone() Set[T] {
+	return maps.Clone(s)
+}
+
+// All iterates over elements of s, in no particular order.
+func (s Set[T]) All() iter.Seq[T] {
+	return maps.Keys(s)
+}
+
+// Slice returns elements of s, in no particular order.
+func (s Set[T]) Slice() []T {
+	ret := make([]T, 0, len(s))
+	for elem := range s {
+		ret = append(ret, elem)
+	}
+	return ret
+}
+
+// Union returns a new Set of elements in either s or other.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	ret := make(Set[T], max(len(s), len(other)))
+	maps.Copy(ret, s)
+	maps.Copy(ret, other)
+	return ret
+}
+
+// Intersect returns a new Set of elements in both s and other.
+func (s Set[T]) Intersect(other Set[T]) Set[T] {
+	if len(s) > len(other) {
+		s, other = other, s
+	}
+	ret := make(Set[T])
+	for elem := range s {
+		if other.Has(elem) {
+			ret[elem] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// Difference returns a new Set of elements in s but not in other.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	ret := make(Set[T])
+	for elem := range s {
+		if !other.Has(elem) {
+			ret[elem] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// IsSubset checks whether all elements of s are in other.
+func (s Set[T]) IsSubset(other Set[T]) bool {
+	if len(s) > len(other) {
+		return false
+	}
+	for elem := range s {
+		if !other.Has(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSuperset checks whether all elements of other are in s.
+func (s Set[T]) IsSuperset(other Set[T]) bool {
+	return other.IsSubset(s)
+}
+
+// Equal checks whether s and other have the same elements.
+func (s Set[T]) Equal(other Set[T]) bool {
+	return len(s) == len(other) && s.IsSubset(other)
+}
+
+func (s Set[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Slice())
+}
+
+func (s *Set[T]) UnmarshalJSON(data []byte) error {
+	var elems []T
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return err
+	}
+	*s = NewSet(elems...)
+	return nil
+}
+
+// SyncSet is a Set safe for concurrent use. Set algebra can be done on a Snapshot of it.
+type SyncSet[T comparable] struct {
+	mu  sync.RWMutex
+	set Set[T]
+}
+
+// NewSyncSet creates a SyncSet of the given elements.
+func NewSyncSet[T comparable](elems ...T) *SyncSet[T] {
+	return &SyncSet[T]{set: NewSet(elems...)}
+}
+
+func (s *SyncSet[T]) Add(elems ...T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	s.set.Add(elems...)
+}
+
+// TryAdd adds elem if absent and returns whether it was added.
+func (s *SyncSet[T]) TryAdd(elem T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.set.Has(elem) {
+		return false
+	}
+	s.init()
+	s.set[elem] = struct{}{}
+	return true
+}
+
+func (s *SyncSet[T]) Remove(elems ...T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Remove(elems...)
+}
+
+func (s *SyncSet[T]) Has(elem T) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Has(elem)
+}
+
+func (s *SyncSet[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.set)
+}
+
+// Snapshot returns a copy of the current elements as a Set.
+func (s *SyncSet[T]) Snapshot() Set[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.set == nil {
+		return make(Set[T])
+	}
+	return s.set.Clone()
+}
+
+// All iterates over a Snapshot of s, so s can be modified while iterating.
+func (s *SyncSet[T]) All() iter.Seq[T] {
+	return s.Snapshot().All()
+}
+
+func (s *SyncSet[T]) Slice() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Slice()
+}
+
+func (s *SyncSet[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Slice())
+}
+
+func (s *SyncSet[T]) UnmarshalJSON(data []byte) error {
+	var set Set[T]
+	if err := set.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set = set
+	return nil
+}
+
+// init initializes the underlying Set of a zero SyncSet. It must be called with the write lock held.
+func (s *SyncSet[T]) init() {
+	if s.set == nil {
+		s.set = make(Set[T])
+	}
+}
@@ -0,0 +1,94 @@
+package kutils
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/kutils/internal/json"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet(1, 2, 3, 2)
+	assert.Equal(t, 3, s.Len())
+	assert.True(t, s.Has(2))
+	assert.False(t, s.Has(4))
+	s.Add(4)
+	s.Remove(1, 5)
+	assert.ElementsMatch(t, []int{2, 3, 4}, s.Slice())
+	assert.ElementsMatch(t, []int{2, 3, 4}, slices.Collect(s.All()))
+	assert.Equal(t, s, SetOf(maps.Keys(map[int]bool{2: true, 3: true, 4: true})))
+
+	clone := s.Clone()
+	clone.Add(5)
+	assert.False(t, s.Has(5))
+
+	other := NewSet(3, 4, 5, 6)
+	assert.Equal(t, NewSet(2, 3, 4, 5, 6), s.Union(other))
+	assert.Equal(t, NewSet(3, 4), s.Intersect(other))
+	assert.Equal(t, NewSet(3, 4), other.Intersect(s))
+	assert.Equal(t, NewSet(2), s.Difference(other))
+	assert.Equal(t, NewSet(5, 6), other.Difference(s))
+
+	assert.True(t, NewSet(3, 4).IsSubset(s))
+	assert.False(t, s.IsSubset(other))
+	assert.True(t, s.IsSuperset(NewSet[int]()))
+	assert.True(t, s.Equal(NewSet(4, 3, 2)))
+	assert.False(t, s.Equal(NewSet(4, 3, 1)))
+}
+
+func TestSet_json(t *testing.T) {
+	type wrapper struct {
+		Set Set[string] `json:"set"`
+	}
+	data, err := json.Marshal(wrapper{Set: NewSet("a")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"set":["a"]}`, string(data))
+
+	var w wrapper
+	require.NoError(t, json.Unmarshal([]byte(`{"set":["a","b","a"]}`), &w))
+	assert.Equal(t, NewSet("a", "b"), w.Set)
+	assert.Error(t, json.Unmarshal([]byte(`{"set":{}}`), &w))
+}
+
+func TestSyncSet(t *testing.T) {
+	var s SyncSet[int]
+	assert.False(t, s.Has(1))
+	assert.Empty(t, s.Snapshot())
+
+	var wg sync.WaitGroup
+	var added sync.Map
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.TryAdd(i % 10) {
+				_, loaded := added.LoadOrStore(i%10, true)
+				assert.False(t, loaded)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, s.Len())
+
+	for elem := range s.All() {
+		s.Remove(elem)
+	}
+	assert.Zero(t, s.Len())
+
+	s.Add(1, 2)
+	data, err := json.Marshal(&s)
+	require.NoError(t, err)
+	var lst []int
+	require.NoError(t, json.Unmarshal(data, &lst))
+	assert.ElementsMatch(t, []int{1, 2}, lst)
+
+	other := NewSyncSet[int]()
+	require.NoError(t, json.Unmarshal(data, other))
+	assert.Equal(t, NewSet(1, 2), other.Snapshot())
+	assert.ElementsMatch(t, []int{1, 2}, other.Slice())
+}
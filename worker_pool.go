@@ -0,0 +1,140 @@
+package kutils
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"github.com/KyberNetwork/kutils/klog"
+)
+
+// WorkerPool runs submitted tasks on at most size goroutines at a time, e.g. for parallel RPC fan-out. It has
+// error-group semantics: the first task error cancels the pool context, which cancels the contexts of running tasks
+// and resolves not yet started tasks with the error as cause. Panics of tasks are recovered and returned as errors.
+type WorkerPool[R any] struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	tasks []*ChanTask[R]
+	err   error
+}
+
+// NewWorkerPool creates a WorkerPool running at most size tasks at a time (unbounded if size <= 0), whose context is
+// derived from ctx.
+func NewWorkerPool[R any](ctx context.Context, size int) *WorkerPool[R] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	var sem chan struct{}
+	if size > 0 {
+		sem = make(chan struct{}, size)
+	}
+	return &WorkerPool[R]{
+		ctx:    ctx,
+		cancel: cancel,
+		sem:    sem,
+	}
+}
+
+// Ctx returns the pool context, which is cancelled with the first task error as cause.
+func (p *WorkerPool[R]) Ctx() context.Context {
+	return p.ctx
+}
+
+// Submit runs fn in a new goroutine once fewer than size tasks are running, blocking until then. fn is called with a
+// context cancelled when either ctx or the pool context is done. The returned task can be awaited with its Result
+// method. An error of fn fails the pool unless ctx is done by then, as the caller has given up on the task.
+func (p *WorkerPool[R]) Submit(ctx context.Context, fn func(ctx context.Context) (R, error)) *ChanTask[R] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	task := NewChanTask[R](ctx)
+	p.mu.Lock()
+	p.tasks = append(p.tasks, task)
+	p.mu.Unlock()
+
+	if p.sem != nil {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			task.Resolve(*new(R), ctx.Err())
+			return task
+		case <-p.ctx.Done():
+			task.Resolve(*new(R), context.Cause(p.ctx))
+			return task
+		}
+	}
+	if p.ctx.Err() != nil {
+		p.release()
+		task.Resolve(*new(R), context.Cause(p.ctx))
+		return task
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.release()
+		taskCtx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		stop := context.AfterFunc(p.ctx, func() { cancel(context.Cause(p.ctx)) })
+		defer stop()
+		ret, err := p.run(taskCtx, fn)
+		if err != nil && ctx.Err() == nil {
+			p.fail(err)
+		}
+		task.Resolve(ret, err)
+	}()
+	return task
+}
+
+// Go submits fn, ignoring its result. The error of fn, if any, is reported by Wait.
+func (p *WorkerPool[R]) Go(fn func(ctx context.Context) error) {
+	p.Submit(p.ctx, func(ctx context.Context) (R, error) {
+		return *new(R), fn(ctx)
+	})
+}
+
+// Wait waits for all submitted tasks to finish and returns their results in submission order, along with the first
+// task error if any. Like errgroup.Group.Wait, it then cancels the pool context, so no more task should be submitted.
+func (p *WorkerPool[R]) Wait() ([]R, error) {
+	p.wg.Wait()
+	p.cancel(nil)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rets := make([]R, len(p.tasks))
+	for i, task := range p.tasks {
+		rets[i] = task.Ret
+	}
+	return rets, p.err
+}
+
+// run calls fn, recovering from its panic if any.
+func (p *WorkerPool[R]) run(ctx context.Context, fn func(ctx context.Context) (R, error)) (ret R, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.Errorf(ctx, "WorkerPool.run|recovered from panic: %v\n%s", r, string(debug.Stack()))
+			err = panicErr(r, "task")
+		}
+	}()
+	return fn(ctx)
+}
+
+// fail records err as the pool error and cancels the pool context if it is the first error.
+func (p *WorkerPool[R]) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+		p.cancel(err)
+	}
+}
+
+func (p *WorkerPool[R]) release() {
+	if p.sem != nil {
+		<-p.sem
+	}
+}
@@ -0,0 +1,90 @@
+package kutils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPool(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ordered results", func(t *testing.T) {
+		pool := NewWorkerPool[int](ctx, 3)
+		var running, maxRunning atomic.Int32
+		tasks := make([]*ChanTask[int], 10)
+		for i := range tasks {
+			tasks[i] = pool.Submit(ctx, func(context.Context) (int, error) {
+				cnt := running.Add(1)
+				defer running.Add(-1)
+				for prev := maxRunning.Load(); cnt > prev && !maxRunning.CompareAndSwap(prev, cnt); {
+					prev = maxRunning.Load()
+				}
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				return i * i, nil
+			})
+		}
+		ret, err := tasks[4].Result()
+		assert.NoError(t, err)
+		assert.Equal(t, 16, ret)
+		rets, err := pool.Wait()
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}, rets)
+		assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	})
+
+	t.Run("first error cancels", func(t *testing.T) {
+		errFirst := errors.New("first")
+		pool := NewWorkerPool[int](ctx, 2)
+		started := make(chan struct{})
+		blocked := pool.Submit(ctx, func(ctx context.Context) (int, error) {
+			close(started)
+			<-ctx.Done()
+			return 0, context.Cause(ctx)
+		})
+		<-started
+		pool.Go(func(context.Context) error { return errFirst })
+		_, err := blocked.Result()
+		assert.ErrorIs(t, err, errFirst)
+
+		var called atomic.Bool
+		late := pool.Submit(ctx, func(context.Context) (int, error) {
+			called.Store(true)
+			return 1, nil
+		})
+		_, err = late.Result()
+		assert.ErrorIs(t, err, errFirst)
+		assert.False(t, called.Load())
+
+		_, err = pool.Wait()
+		assert.ErrorIs(t, err, errFirst)
+		assert.ErrorIs(t, context.Cause(pool.Ctx()), errFirst)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		pool := NewWorkerPool[int](ctx, 0)
+		task := pool.Submit(ctx, func(context.Context) (int, error) {
+			panic("test panic")
+		})
+		_, err := task.Result()
+		assert.ErrorContains(t, err, "task panicked: test panic")
+		_, err = pool.Wait()
+		assert.ErrorContains(t, err, "test panic")
+	})
+
+	t.Run("per-task context", func(t *testing.T) {
+		pool := NewWorkerPool[int](ctx, 1)
+		taskCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		task := pool.Submit(taskCtx, func(ctx context.Context) (int, error) {
+			return 0, ctx.Err()
+		})
+		_, err := task.Result()
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NoError(t, pool.Ctx().Err())
+	})
+}